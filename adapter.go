@@ -0,0 +1,118 @@
+package cosweb
+
+import (
+	"context"
+	"net/http"
+)
+
+// net/http 适配:
+//   - WrapHandler     将 http.Handler 挂载为路由 handler(pprof、指标导出、第三方 UI)
+//   - WrapMiddleware  在 cosweb 中间件链中复用 func(http.Handler) http.Handler 中间件
+//   - Server.Middleware 反向导出,将 cosweb 全局中间件用于 net/http 处理链
+//
+// 经过适配的 net/http 代码可通过 FromContext(r.Context()) 取回 *Context。
+
+type contextKey struct{}
+
+type wrapKey struct{}
+
+// wrapCall 一次 WrapMiddleware 调用的状态,通过 Request.Context 传递给链尾的 wrapNext
+type wrapCall struct {
+	c      *Context
+	next   Next
+	err    error
+	called bool
+}
+
+// FromContext 从 Request.Context() 取回 *Context,仅在适配器调用的 net/http 代码中可用
+func FromContext(ctx context.Context) (*Context, bool) {
+	c, ok := ctx.Value(contextKey{}).(*Context)
+	return c, ok
+}
+
+// httpRequest 返回携带 *Context 的 Request,已携带时直接返回
+func (c *Context) httpRequest() *http.Request {
+	if v, ok := FromContext(c.Request.Context()); ok && v == c {
+		return c.Request
+	}
+	c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), contextKey{}, c))
+	return c.Request
+}
+
+// WrapHandler 将 http.Handler 转换为 HandlerFunc。
+// handler 直接写 c.Response,返回后响应视为已写出,HTTPErrorHandler 与序列化不再重复写入。
+//
+//	s.Register("/debug/pprof/*", cosweb.WrapHandler(http.DefaultServeMux), http.MethodGet)
+func WrapHandler(h http.Handler) HandlerFunc {
+	return func(c *Context) any {
+		h.ServeHTTP(c.Response, c.httpRequest())
+		c.Response.written = true
+		return nil
+	}
+}
+
+// WrapMiddleware 将 net/http 中间件转换为 MiddlewareFunc。
+// 中间件调用 next 时继续执行 cosweb 后续链,传入的 *http.Request 与 http.ResponseWriter 会替换
+// c.Request 与 c.Response 供后续链使用;未调用 next 时视为中间件已自行响应。
+//
+//	s.Use(cosweb.WrapMiddleware(handlers.CompressHandler))
+func WrapMiddleware(m func(http.Handler) http.Handler) MiddlewareFunc {
+	h := m(http.HandlerFunc(wrapNext))
+	return func(c *Context, next Next) error {
+		call := &wrapCall{c: c, next: next}
+		r := c.httpRequest()
+		r = r.WithContext(context.WithValue(r.Context(), wrapKey{}, call))
+		res := c.Response
+		h.ServeHTTP(res, r)
+		if !call.called {
+			res.written = true
+		}
+		return call.err
+	}
+}
+
+// wrapNext WrapMiddleware 链尾,回到 cosweb 中间件链
+func wrapNext(w http.ResponseWriter, r *http.Request) {
+	call := r.Context().Value(wrapKey{}).(*wrapCall)
+	call.called = true
+	c := call.c
+	c.Request = r
+	res := c.Response
+	if rw, ok := w.(*Response); !ok || rw != res {
+		c.Response = &Response{ResponseWriter: w}
+	}
+	call.err = call.next()
+	if inner := c.Response; inner != res {
+		res.written = res.written || inner.written
+		res.hijacked = res.hijacked || inner.hijacked
+		c.Response = res
+	}
+}
+
+// Middleware 将 Server 的全局中间件导出为 net/http 中间件,全局中间件执行完毕后调用 next。
+//
+//	mux.Handle("/legacy/", s.Middleware()(legacyHandler))
+func (srv *Server) Middleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		final := func(c *Context, _ Next) error {
+			next.ServeHTTP(c.Response, c.httpRequest())
+			c.Response.written = true
+			return nil
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			c := srv.Acquire(w, r)
+			defer func() {
+				if e := recover(); e != nil {
+					HTTPErrorHandler(c, e)
+				}
+				srv.Release(c)
+			}()
+			funcs := make([]MiddlewareFunc, 0, len(srv.middleware)+1)
+			funcs = append(funcs, srv.middleware...)
+			c.dp.funcs = append(funcs, final)
+			if err := c.doDispatch(); err != nil {
+				HTTPErrorHandler(c, err)
+			}
+		})
+	}
+}
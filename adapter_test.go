@@ -0,0 +1,86 @@
+package cosweb
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type upperWriter struct {
+	http.ResponseWriter
+}
+
+func (w upperWriter) Write(b []byte) (int, error) {
+	return w.ResponseWriter.Write([]byte(strings.ToUpper(string(b))))
+}
+
+// TestWrapHandler 验证 http.Handler 挂载后可取回 *Context,且不会被重复写入。
+func TestWrapHandler(t *testing.T) {
+	s := New()
+	s.Use(func(c *Context, next Next) error {
+		c.Set("uid", "u-1")
+		return next()
+	})
+	s.GET("/h", WrapHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, ok := FromContext(r.Context())
+		if !ok {
+			http.Error(w, "no context", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = w.Write([]byte(c.GetString("uid")))
+	})))
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/h", nil))
+	if w.Code != http.StatusAccepted || w.Body.String() != "u-1" {
+		t.Errorf("got %d %q", w.Code, w.Body.String())
+	}
+}
+
+// TestWrapMiddleware 验证 net/http 中间件可替换 ResponseWriter,也可不调用 next 直接响应。
+func TestWrapMiddleware(t *testing.T) {
+	s := New()
+	s.Use(WrapMiddleware(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("deny") != "" {
+				http.Error(w, "denied", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(upperWriter{w}, r)
+		})
+	}))
+	s.GET("/x", func(c *Context) any {
+		_ = c.String("hello")
+		return nil
+	})
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/x", nil))
+	if w.Body.String() != "HELLO" {
+		t.Errorf("expected wrapped writer output, got %q", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/x?deny=1", nil))
+	if w.Code != http.StatusForbidden || strings.TrimSpace(w.Body.String()) != "denied" {
+		t.Errorf("expected 403 denied, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+// TestServerMiddleware 验证全局中间件导出为 net/http 中间件。
+func TestServerMiddleware(t *testing.T) {
+	s := New()
+	s.Use(func(c *Context, next Next) error {
+		c.Header().Set("X-Cosweb", "1")
+		return next()
+	})
+	h := s.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("legacy"))
+	}))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/any", nil))
+	if w.Header().Get("X-Cosweb") != "1" || w.Body.String() != "legacy" {
+		t.Errorf("got header %q body %q", w.Header().Get("X-Cosweb"), w.Body.String())
+	}
+}
@@ -0,0 +1,231 @@
+package cosweb
+
+import (
+	"encoding"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// bindTags BindAll 支持的标签及对应的数据来源
+var bindTags = []struct {
+	tag      string
+	dataType RequestDataType
+}{
+	{"param", RequestDataTypeParam},
+	{"query", RequestDataTypeQuery},
+	{"header", RequestDataTypeHeader},
+	{"cookie", RequestDataTypeCookie},
+	{"form", RequestDataTypeBody},
+}
+
+// bindField 结构体字段的绑定信息
+type bindField struct {
+	index    []int
+	name     string //来源中的名称,用于错误提示
+	key      string
+	dataType RequestDataType
+	tagged   bool //是否声明了来源标签,否则只处理 default
+	def      string
+	hasDef   bool
+	layout   string //time.Time 的格式,默认 RFC3339
+}
+
+var bindCache sync.Map // reflect.Type → []bindField
+
+var (
+	timeType         = reflect.TypeOf(time.Time{})
+	durationType     = reflect.TypeOf(time.Duration(0))
+	textUnmarshaler  = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	errBindNotStruct = NewHTTPError(http.StatusInternalServerError, "BindAll requires a pointer to struct")
+)
+
+// BindAll 从请求的各个来源绑定结构体:
+//
+//	type Req struct {
+//		ID    int64     `param:"id"`
+//		Page  int       `query:"page" default:"1"`
+//		Tags  []string  `query:"tag"`
+//		Token string    `header:"X-Token"`
+//		SID   string    `cookie:"sid"`
+//		Name  string    `form:"name"`
+//		Since time.Time `query:"since" layout:"2006-01-02"`
+//		Body  string    `json:"body"` //JSON、XML 等请求体字段
+//	}
+//
+// 先按 Content-Type 绑定请求体(urlencoded、multipart 通过 form 标签读取),再按标签填充其他来源;
+// 来源中不存在时使用 default,转换失败时返回 400 HTTPError 并指明字段。
+func (c *Context) BindAll(i any) error {
+	v := reflect.ValueOf(i)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return errBindNotStruct
+	}
+	if c.hasBody() && !c.isForm() && !c.isMultipart() {
+		if err := c.Bind(i); err != nil {
+			return NewHTTPError(http.StatusBadRequest, "invalid request body: %v", err)
+		}
+	}
+	v = v.Elem()
+	for _, f := range bindFields(v.Type()) {
+		fv := v.FieldByIndex(f.index)
+		var vs []string
+		if f.tagged {
+			vs, _ = c.getAllFromStore(f.key, f.dataType)
+		}
+		if len(vs) == 0 {
+			if !f.hasDef || (!f.tagged && !fv.IsZero()) {
+				continue
+			}
+			vs = []string{f.def}
+		}
+		if err := setField(fv, vs, f.layout); err != nil {
+			return NewHTTPError(http.StatusBadRequest, "invalid field %s: %v", f.name, err)
+		}
+	}
+	return nil
+}
+
+// hasBody 请求是否携带请求体
+func (c *Context) hasBody() bool {
+	if c.spool != nil {
+		return c.spool.size > 0
+	}
+	if c.body != nil {
+		return len(c.body) > 0
+	}
+	return c.Request.Body != nil && c.Request.Body != http.NoBody && c.Request.ContentLength != 0 &&
+		c.Request.Header.Get(HeaderContentType) != ""
+}
+
+// bindFields 解析并缓存结构体字段的绑定信息,匿名嵌入的结构体展开处理
+func bindFields(t reflect.Type) []bindField {
+	if v, ok := bindCache.Load(t); ok {
+		return v.([]bindField)
+	}
+	var fields []bindField
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct && sf.Type != timeType {
+			for _, f := range bindFields(sf.Type) {
+				f.index = append([]int{i}, f.index...)
+				fields = append(fields, f)
+			}
+			continue
+		}
+		f := bindField{index: []int{i}, name: sf.Name, layout: sf.Tag.Get("layout")}
+		f.def, f.hasDef = sf.Tag.Lookup("default")
+		for _, bt := range bindTags {
+			if key, ok := sf.Tag.Lookup(bt.tag); ok && key != "-" {
+				if key = strings.Split(key, ",")[0]; key == "" {
+					key = sf.Name
+				}
+				f.key, f.name, f.dataType, f.tagged = key, key, bt.dataType, true
+				break
+			}
+		}
+		if f.tagged || f.hasDef {
+			fields = append(fields, f)
+		}
+	}
+	bindCache.Store(t, fields)
+	return fields
+}
+
+// setField 将字符串值转换后写入字段,切片使用全部值,其他类型使用第一个值
+func setField(v reflect.Value, vs []string, layout string) error {
+	if v.Kind() == reflect.Slice && v.Type().Elem().Kind() != reflect.Uint8 {
+		s := reflect.MakeSlice(v.Type(), len(vs), len(vs))
+		for i, str := range vs {
+			if err := setValue(s.Index(i), str, layout); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+		return nil
+	}
+	return setValue(v, vs[0], layout)
+}
+
+func setValue(v reflect.Value, s string, layout string) error {
+	if v.Kind() == reflect.Pointer {
+		p := reflect.New(v.Type().Elem())
+		if err := setValue(p.Elem(), s, layout); err != nil {
+			return err
+		}
+		v.Set(p)
+		return nil
+	}
+	if v.CanAddr() && v.Addr().Type().Implements(textUnmarshaler) && v.Type() != timeType {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+	switch v.Type() {
+	case timeType:
+		t, err := parseTime(s, layout)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	case durationType:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	case reflect.Slice:
+		v.SetBytes([]byte(s))
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// parseTime 按 layout 解析时间,未指定时依次尝试 RFC3339 与 Unix 秒
+func parseTime(s, layout string) (time.Time, error) {
+	if layout != "" {
+		return time.Parse(layout, s)
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(n, 0), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", s)
+}
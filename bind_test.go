@@ -0,0 +1,71 @@
+package cosweb
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestBindAll 验证从路径参数、query、请求头、Cookie、表单与 JSON 请求体绑定结构体。
+func TestBindAll(t *testing.T) {
+	type Page struct {
+		Page int `query:"page" default:"1"`
+		Size int `query:"size" default:"10"`
+	}
+	type Req struct {
+		Page
+		ID     int64         `param:"id"`
+		Tags   []string      `query:"tag"`
+		Flag   *bool         `query:"flag"`
+		Since  time.Time     `query:"since" layout:"2006-01-02"`
+		Wait   time.Duration `query:"wait"`
+		Token  string        `header:"X-Token"`
+		SID    string        `cookie:"sid"`
+		Name   string        `form:"name"`
+		Scores []int         `form:"score"`
+		Body   string        `json:"body"`
+	}
+	s := New()
+	var req Req
+	s.Register("/item/:id<int>", func(c *Context) any {
+		req = Req{}
+		return c.BindAll(&req)
+	}, http.MethodPost)
+
+	r := httptest.NewRequest(http.MethodPost, "/item/7?page=3&tag=a&tag=b&flag=true&since=2024-05-01&wait=2s", strings.NewReader("name=n&score=1&score=2"))
+	r.Header.Set(HeaderContentType, "application/x-www-form-urlencoded")
+	r.Header.Set("X-Token", "tk")
+	r.AddCookie(&http.Cookie{Name: "sid", Value: "s1"})
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("form: %d %q", w.Code, w.Body.String())
+	}
+	want := Req{Page: Page{Page: 3, Size: 10}, ID: 7, Tags: []string{"a", "b"}, Since: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+		Wait: 2 * time.Second, Token: "tk", SID: "s1", Name: "n", Scores: []int{1, 2}}
+	if req.Flag == nil || !*req.Flag {
+		t.Errorf("Flag: got %v", req.Flag)
+	}
+	req.Flag = nil
+	if !reflect.DeepEqual(req, want) {
+		t.Errorf("form: got %+v\nwant %+v", req, want)
+	}
+
+	r = httptest.NewRequest(http.MethodPost, "/item/8", strings.NewReader(`{"body":"json"}`))
+	r.Header.Set(HeaderContentType, "application/json")
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || req.Body != "json" || req.ID != 8 || req.Page.Page != 1 {
+		t.Errorf("json: %d %+v", w.Code, req)
+	}
+
+	r = httptest.NewRequest(http.MethodPost, "/item/9?page=x", nil)
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "page") {
+		t.Errorf("invalid field: %d %q", w.Code, w.Body.String())
+	}
+}
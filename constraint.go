@@ -0,0 +1,162 @@
+package cosweb
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/hwcer/cosgo/registry"
+)
+
+// ParamParser 路由参数约束,返回转换后的值,不满足约束时返回 false
+type ParamParser func(string) (any, bool)
+
+// paramParsers 参数约束注册表,约定仅在启动阶段注册
+var paramParsers = map[string]ParamParser{
+	"int": func(s string) (any, bool) {
+		v, err := strconv.ParseInt(s, 10, 64)
+		return v, err == nil
+	},
+	"uint": func(s string) (any, bool) {
+		v, err := strconv.ParseUint(s, 10, 64)
+		return v, err == nil
+	},
+	"float": func(s string) (any, bool) {
+		v, err := strconv.ParseFloat(s, 64)
+		return v, err == nil
+	},
+	"bool": func(s string) (any, bool) {
+		v, err := strconv.ParseBool(s)
+		return v, err == nil
+	},
+	"alpha": func(s string) (any, bool) {
+		for i := 0; i < len(s); i++ {
+			if c := s[i] | 0x20; c < 'a' || c > 'z' {
+				return nil, false
+			}
+		}
+		return s, s != ""
+	},
+	"uuid": func(s string) (any, bool) {
+		if len(s) != 36 {
+			return nil, false
+		}
+		for i := 0; i < len(s); i++ {
+			switch i {
+			case 8, 13, 18, 23:
+				if s[i] != '-' {
+					return nil, false
+				}
+			default:
+				if !isHex(s[i]) {
+					return nil, false
+				}
+			}
+		}
+		return s, true
+	},
+}
+
+// RegisterParamParser 注册路由参数约束类型,路由中以 :name<type> 使用,仅在启动阶段调用
+func RegisterParamParser(name string, parser ParamParser) {
+	paramParsers[name] = parser
+}
+
+// paramConstraint 单个路由参数约束
+type paramConstraint struct {
+	name  string
+	parse ParamParser
+}
+
+// paramValue 约束转换后的参数值,c.Get 优先于原始字符串返回
+type paramValue struct {
+	key   string
+	value any
+}
+
+// parseConstraints 剥离路由中的参数约束,返回 registry 可识别的路由。
+// 语法: /user/:id<int>、/file/:name<re:[a-z]+\.png>、/u/:uuid<uuid>;
+// 约束以 '>' 后紧跟 '/' 或路由结尾作为结束,正则中可以包含 '<'、'>' 和 '/'。
+func parseConstraints(path string) (string, []paramConstraint, error) {
+	if !strings.Contains(path, "<") {
+		return path, nil, nil
+	}
+	var b strings.Builder
+	var constraints []paramConstraint
+	for i := 0; i < len(path); {
+		if path[i] != ':' || (i > 0 && path[i-1] != '/') {
+			b.WriteByte(path[i])
+			i++
+			continue
+		}
+		end := i + 1
+		for end < len(path) && path[end] != '/' && path[end] != '<' {
+			end++
+		}
+		name := path[i+1 : end]
+		b.WriteString(path[i:end])
+		i = end
+		if end >= len(path) || path[end] != '<' {
+			continue
+		}
+		closing := -1
+		for j := end + 1; j < len(path); j++ {
+			if path[j] == '>' && (j+1 == len(path) || path[j+1] == '/') {
+				closing = j
+				break
+			}
+		}
+		if closing < 0 {
+			return "", nil, fmt.Errorf("route param constraint not closed:%s", path)
+		}
+		parser, err := newParamParser(path[end+1 : closing])
+		if err != nil {
+			return "", nil, fmt.Errorf("route %s param %s: %w", path, name, err)
+		}
+		constraints = append(constraints, paramConstraint{name: name, parse: parser})
+		i = closing + 1
+	}
+	return b.String(), constraints, nil
+}
+
+func newParamParser(typ string) (ParamParser, error) {
+	if expr, ok := strings.CutPrefix(typ, "re:"); ok {
+		re, err := regexp.Compile("^(?:" + expr + ")$")
+		if err != nil {
+			return nil, err
+		}
+		return func(s string) (any, bool) {
+			return s, re.MatchString(s)
+		}, nil
+	}
+	if parser, ok := paramParsers[typ]; ok {
+		return parser, nil
+	}
+	return nil, fmt.Errorf("unknown param constraint:%s", typ)
+}
+
+// constrain 校验路由参数约束,通过时将转换后的值写入 c.values
+func (r *routeConfig) constrain(c *Context, params registry.Params) bool {
+	if len(r.constraints) == 0 {
+		return true
+	}
+	values := c.values[:0]
+	for _, pc := range r.constraints {
+		s, ok := params.Get(pc.name)
+		if !ok {
+			return false
+		}
+		v, ok := pc.parse(s)
+		if !ok {
+			return false
+		}
+		values = append(values, paramValue{key: pc.name, value: v})
+	}
+	c.values = values
+	return true
+}
+
+func isHex(c byte) bool {
+	return ('0' <= c && c <= '9') || ('a' <= c && c <= 'f') || ('A' <= c && c <= 'F')
+}
@@ -2,15 +2,18 @@ package cosweb
 
 import (
 	"bytes"
+	"errors"
 	"io"
-	"net"
 	"net/http"
+	"net/url"
 	"strings"
+	"sync/atomic"
 
 	"github.com/hwcer/cosgo/binder"
 	"github.com/hwcer/cosgo/registry"
 	"github.com/hwcer/cosgo/session"
 	"github.com/hwcer/cosgo/values"
+	"github.com/hwcer/cosweb/websocket"
 )
 
 const (
@@ -28,18 +31,30 @@ func (mid *dispatch) Release() {
 
 // Context API上下文.
 type Context struct {
-	body       []byte
-	accept     binder.Binder                     //客户端接受的序列化方式
-	stores     map[RequestDataType]values.Values // 统一存储所有参数
-	node       *registry.Node                    // 当前匹配的路由节点（避免闭包分配）
-	params     registry.Params                   // 当前路径参数
-	dp         dispatch
-	dispatchFn Next     // 缓存 c.doDispatch 方法值，避免每次传递时分配
-	response   Response // 内嵌值，避免每次请求堆分配
-	Server     *Server
-	Session    *session.Session
-	Request    *http.Request
-	Response   *Response
+	body         []byte
+	accept       binder.Binder                     //客户端接受的序列化方式
+	stores       map[RequestDataType]values.Values // 统一存储所有参数
+	forms        map[RequestDataType]url.Values    // query、urlencoded body 的全部值,stores 中只保留第一个
+	multipart    *MultipartForm                    // 已解析的 multipart 表单,release 时删除临时文件
+	multipartErr error
+	ctx          atomic.Pointer[ctxState] // 作为 context.Context 使用时创建,释放时取消
+	decoded      io.Closer                // 解压请求体使用的解压器,释放时关闭
+	spool        *spooledBody             // 超过 MaxCacheSize 的请求体临时文件,释放时删除
+	sse          *SSE                     // c.SSE() 创建的写入器,释放时关闭
+	maxBodySize  int64                    // 请求级最大请求体大小,参见 SetMaxBodySize
+	maxCacheSize int64
+	node         *registry.Node  // 当前匹配的路由节点（避免闭包分配）
+	params       registry.Params // 当前路径参数
+	allow        []string        // 路径存在但方法不匹配时允许的方法,用于 405/OPTIONS
+	values       []paramValue    // 路由参数约束转换后的值,如 :id<int> 的 int64
+	entry        *routeEntry     // 当前方法生效的 handler 与路由选项,struct 批量注册的节点为 nil
+	dp           dispatch
+	dispatchFn   Next     // 缓存 c.doDispatch 方法值，避免每次传递时分配
+	response     Response // 内嵌值，避免每次请求堆分配
+	Server       *Server
+	Session      *session.Session
+	Request      *http.Request
+	Response     *Response
 }
 
 // NewContext returns a Context instance.
@@ -59,26 +74,54 @@ func (c *Context) reset(w http.ResponseWriter, r *http.Request) {
 	c.response.status = 0
 	c.response.written = false
 	c.response.hijacked = false
+	c.response.discard = false
 	c.Response = &c.response
 	c.node = nil
 	c.params = nil
+	c.allow = nil
+	c.values = c.values[:0]
+	c.entry = nil
 	c.dp = dispatch{}
+	c.maxBodySize = 0
+	c.maxCacheSize = 0
 	clear(c.stores)
+	clear(c.forms)
 }
 
-// 释放资源,准备进入缓存池
-func (c *Context) release() {
+// 释放资源,准备进入缓存池;
+// escaped 为 true 时 Context 不再复用,只关闭文件等资源,保留 Value 读取的字段,
+// 避免仍持有它的 goroutine(如 context.WithTimeout 的监听协程)与清理发生数据竞争
+func (c *Context) release(escaped bool) {
+	if c.sse != nil {
+		c.sse.Close()
+		c.sse = nil
+	}
+	if c.multipart != nil {
+		c.multipart.removeAll()
+		c.multipart, c.multipartErr = nil, nil
+	}
+	if c.decoded != nil {
+		_ = c.decoded.Close()
+		c.decoded = nil
+	}
+	if c.spool != nil {
+		c.spool.remove()
+		c.spool = nil
+	}
+	c.dp.Release()
+	c.Session.Release()
+	if escaped {
+		return
+	}
 	c.body = nil
 	c.accept = nil
 	clear(c.stores)
+	clear(c.forms)
 	c.Request = nil
 	c.response.ResponseWriter = nil
 	c.Response = nil
-	c.dp.Release()
-	c.Session.Release()
 }
 
-
 func (c *Context) doDispatch() error {
 	if c.dp.index < len(c.dp.funcs) {
 		mf := c.dp.funcs[c.dp.index]
@@ -86,7 +129,10 @@ func (c *Context) doDispatch() error {
 		return mf(c, c.dispatchFn)
 	}
 	if c.node == nil {
-		return ErrNotFound
+		if len(c.allow) > 0 {
+			return c.methodNotAllowed()
+		}
+		return c.notFound()
 	}
 	handler, ok := c.node.Handler().(*Handler)
 	if !ok {
@@ -99,46 +145,85 @@ func (c *Context) doDispatch() error {
 	return handler.write(c, reply)
 }
 
-// IsWebSocket 判断是否WebSocket
-func (c *Context) IsWebSocket() bool {
-	return strings.EqualFold(c.Request.Header.Get(HeaderUpgrade), "websocket")
+// methodNotAllowed 路径在其他方法下存在时的响应:405(或自动 OPTIONS)
+func (c *Context) methodNotAllowed() error {
+	c.Header().Set(HeaderAllow, strings.Join(c.allow, ", "))
+	if c.Request.Method == http.MethodOptions && c.Server.AutoOptions {
+		c.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	return ErrMethodNotAllowed
 }
 
-// Protocol 协议
-func (c *Context) Protocol() string {
-	// Can't use `r.Request.URL.Protocol`
-	// See: https://groups.google.com/forum/#!topic/golang-nuts/pMUkBlQBDF0
-	if c.Request.TLS != nil {
-		return "https"
+// notFound 未匹配到路由时依次尝试 Fallback,返回 404 错误时继续下一个,其他 error 交给 HTTPErrorHandler;
+// 全部未处理时交给 NotFound handler,以 404 状态写出其返回值
+func (c *Context) notFound() error {
+	srv := c.Server
+	if len(srv.fallback) == 0 && srv.notFound == nil {
+		return ErrNotFound
 	}
-	if scheme := c.Request.Header.Get(HeaderXForwardedProto); scheme != "" {
-		return scheme
+	handler := srv.rootHandler()
+	for _, f := range srv.fallback {
+		reply := f(c)
+		if !c.Response.CanWrite() {
+			return nil
+		}
+		if e, ok := reply.(error); ok {
+			if isNotFound(e) {
+				continue
+			}
+			return e
+		}
+		return handler.write(c, reply)
 	}
-	if scheme := c.Request.Header.Get(HeaderXForwardedProtocol); scheme != "" {
-		return scheme
+	if srv.notFound == nil {
+		return ErrNotFound
 	}
-	if ssl := c.Request.Header.Get(HeaderXForwardedSsl); ssl == "on" {
-		return "https"
+	reply := srv.notFound(c)
+	if !c.Response.CanWrite() {
+		return nil
 	}
-	if scheme := c.Request.Header.Get(HeaderXUrlScheme); scheme != "" {
-		return scheme
+	if e, ok := reply.(error); ok {
+		return e
 	}
-	return "http"
+	return handler.writeStatus(c, http.StatusNotFound, reply)
 }
 
-// RemoteAddr 客户端地址
-func (c *Context) RemoteAddr() string {
-	if ip := c.Request.Header.Get(HeaderXForwardedFor); ip != "" {
-		if i := strings.IndexByte(ip, ','); i >= 0 {
-			return ip[:i]
-		}
-		return ip
+func isNotFound(err error) bool {
+	var e *HTTPError
+	return errors.As(err, &e) && e.Code == http.StatusNotFound
+}
+
+// IsWebSocket 判断是否WebSocket
+func (c *Context) IsWebSocket() bool {
+	return strings.EqualFold(c.Request.Header.Get(HeaderUpgrade), "websocket")
+}
+
+// Upgrade 将 WebSocket 握手请求升级为连接,upgrader 为空时使用默认配置;
+// 未设置 CheckOrigin 时要求 Origin 与 c.Host() 相同(经可信代理时使用 X-Forwarded-Host)。
+// 握手失败时已写出错误响应,成功后 Response 处于劫持状态,handler 返回值不会再写入
+func (c *Context) Upgrade(upgrader ...*websocket.Upgrader) (*websocket.Conn, error) {
+	var u websocket.Upgrader
+	if len(upgrader) > 0 && upgrader[0] != nil {
+		u = *upgrader[0]
 	}
-	if ip := c.Request.Header.Get(HeaderXRealIP); ip != "" {
-		return ip
+	if u.CheckOrigin == nil {
+		host := c.Host()
+		u.CheckOrigin = func(r *http.Request) bool {
+			return websocket.MatchOrigin(r.Header.Get(HeaderOrigin), host)
+		}
 	}
-	ra, _, _ := net.SplitHostPort(c.Request.RemoteAddr)
-	return ra
+	return u.Upgrade(c.Response, c.Request, c.Header())
+}
+
+// Protocol 协议,参见 Scheme
+func (c *Context) Protocol() string {
+	return c.Scheme()
+}
+
+// RemoteAddr 客户端地址,参见 RealIP
+func (c *Context) RemoteAddr() string {
+	return c.RealIP()
 }
 
 func (c *Context) Set(key string, val any) {
@@ -168,6 +253,12 @@ func (c *Context) Get(key string, dataTypes ...RequestDataType) any {
 func (c *Context) getDataFromStore(key string, dataType RequestDataType) (any, bool) {
 	switch dataType {
 	case RequestDataTypeParam:
+		// 约束参数已转换为目标类型,GetInt64 等无需重新解析
+		for i := range c.values {
+			if c.values[i].key == key {
+				return c.values[i].value, true
+			}
+		}
 		// 直接从 c.params 线性查找，无需创建 map
 		if v, ok := c.params.Get(key); ok {
 			return v, true
@@ -202,15 +293,18 @@ func (c *Context) getOrCreateStore(dataType RequestDataType) (values.Values, boo
 	var newStore values.Values
 	switch dataType {
 	case RequestDataTypeQuery:
-		newStore = values.Values{}
-		for k, v := range c.Request.URL.Query() {
-			if len(v) > 0 {
-				newStore.Set(k, v[0])
-			}
-		}
+		newStore = c.setForm(dataType, c.Request.URL.Query())
 	case RequestDataTypeBody:
-		newStore = values.Values{}
-		_ = c.Bind(&newStore)
+		if c.isForm() {
+			vs, _ := c.parseForm()
+			newStore = c.setForm(dataType, vs)
+		} else if c.isMultipart() {
+			form, _ := c.MultipartForm()
+			newStore = c.setForm(dataType, form.Value)
+		} else {
+			newStore = values.Values{}
+			_ = c.Bind(&newStore)
+		}
 	case RequestDataTypeContext:
 		newStore = values.Values{}
 	}
@@ -221,6 +315,105 @@ func (c *Context) getOrCreateStore(dataType RequestDataType) (values.Values, boo
 	return nil, false
 }
 
+// setForm 保存多值参数,返回只保留第一个值的单值存储
+func (c *Context) setForm(dataType RequestDataType, vs url.Values) values.Values {
+	if c.forms == nil {
+		c.forms = make(map[RequestDataType]url.Values)
+	}
+	c.forms[dataType] = vs
+	store := values.Values{}
+	for k, v := range vs {
+		if len(v) > 0 {
+			store.Set(k, v[0])
+		}
+	}
+	return store
+}
+
+// isForm 请求体是否为 application/x-www-form-urlencoded
+func (c *Context) isForm() bool {
+	t := c.Request.Header.Get(HeaderContentType)
+	return strings.HasPrefix(strings.ToLower(t), binder.MIMEPOSTForm)
+}
+
+// parseForm 解析 urlencoded 请求体,保留重复字段的全部值
+func (c *Context) parseForm() (url.Values, error) {
+	b, err := c.Buffer()
+	if err != nil {
+		return nil, err
+	}
+	return url.ParseQuery(b.String())
+}
+
+// getAllFromStore 获取参数的全部值
+func (c *Context) getAllFromStore(key string, dataType RequestDataType) ([]string, bool) {
+	switch dataType {
+	case RequestDataTypeQuery, RequestDataTypeBody:
+		if _, ok := c.getOrCreateStore(dataType); !ok {
+			return nil, false
+		}
+		if vs, ok := c.forms[dataType]; ok {
+			v, ok := vs[key]
+			return v, ok && len(v) > 0
+		}
+	case RequestDataTypeCookie:
+		var r []string
+		for _, cookie := range c.Request.Cookies() {
+			if cookie.Name == key && cookie.Value != "" {
+				r = append(r, cookie.Value)
+			}
+		}
+		return r, len(r) > 0
+	case RequestDataTypeHeader:
+		v := c.Request.Header.Values(key)
+		return v, len(v) > 0
+	}
+	// 路径参数、上下文数据以及 JSON 等请求体,数组按元素展开
+	v, ok := c.getDataFromStore(key, dataType)
+	if !ok {
+		return nil, false
+	}
+	switch d := v.(type) {
+	case []string:
+		return d, true
+	case []any:
+		r := make([]string, len(d))
+		for i, e := range d {
+			r[i] = values.ParseString(e)
+		}
+		return r, true
+	default:
+		return []string{values.ParseString(v)}, true
+	}
+}
+
+// GetStrings 获取参数的全部值,如 ?tag=a&tag=b、重复的表单字段、请求头与 Cookie;
+// 单值参数返回长度为 1 的切片,不存在时返回 nil
+func (c *Context) GetStrings(key string, dataTypes ...RequestDataType) []string {
+	if len(dataTypes) == 0 {
+		dataTypes = c.Server.RequestDataType
+	}
+	for _, t := range dataTypes {
+		if v, ok := c.getAllFromStore(key, t); ok {
+			return v
+		}
+	}
+	return nil
+}
+
+// GetInts 获取参数的全部值并转换为int,参见 GetStrings
+func (c *Context) GetInts(key string, dataTypes ...RequestDataType) []int {
+	vs := c.GetStrings(key, dataTypes...)
+	if vs == nil {
+		return nil
+	}
+	r := make([]int, len(vs))
+	for i, v := range vs {
+		r[i] = int(values.ParseInt64(v))
+	}
+	return r
+}
+
 // GetInt 获取int类型参数
 func (c *Context) GetInt(key string, dataTypes ...RequestDataType) int {
 	return int(values.ParseInt64(c.Get(key, dataTypes...)))
@@ -256,62 +449,39 @@ func (c *Context) Bind(i any) (err error) {
 	if encoder == nil {
 		return values.Errorf(0, "unknown content type: %s", t)
 	}
-	// 已缓存时直接使用，跳过 Buffer() 的 bytes.Buffer 结构体分配
-	if c.body != nil {
-		if len(c.body) > 0 {
-			return encoder.Unmarshal(c.body, i)
-		}
-		return nil
-	}
-	var b *bytes.Buffer
-	if b, err = c.Buffer(); err != nil {
+	if err = c.loadBody(); err != nil {
 		return err
 	}
-	if b.Len() > 0 {
-		err = encoder.Unmarshal(b.Bytes(), i)
+	c.rewindBody()
+	// 写入临时文件的大请求体流式解码,不整体加载到内存
+	if c.spool != nil {
+		return encoder.Decode(c.spool.reader(), i)
+	}
+	if len(c.body) > 0 {
+		return encoder.Unmarshal(c.body, i)
 	}
-	return err
+	return nil
 }
 
-// Buffer 获取绑定body bytes
+// Buffer 获取绑定body bytes,可重复调用;超过 MaxCacheSize 的请求体每次从临时文件读取
 func (c *Context) Buffer() (b *bytes.Buffer, err error) {
-	if c.body != nil {
-		return bytes.NewBuffer(c.body), nil
+	if err = c.loadBody(); err != nil {
+		return nil, err
 	}
-	initCap := 256
-	if cl := c.Request.ContentLength; cl > 0 && cl <= c.Server.MaxBodySize {
-		initCap = int(cl)
-	}
-	b = bytes.NewBuffer(make([]byte, 0, initCap))
-	defer func() {
-		// 只有在没有错误的情况下才恢复 c.Request.Body 和缓存数据
-		if err == nil {
-			// 恢复 c.Request.Body，使其可重复读取
-			c.Request.Body = io.NopCloser(bytes.NewReader(b.Bytes()))
-			// 只有当内容大小小于等于最大缓存大小时才缓存
-			if b.Len() <= int(c.Server.MaxCacheSize) {
-				c.body = b.Bytes()
-			}
-		}
-	}()
-	var n int64
-	// 多读一字节以区分"恰好等于上限"和"超过上限"
-	reader := io.LimitReader(c.Request.Body, c.Server.MaxBodySize+1)
-	n, err = b.ReadFrom(reader)
-	if err != nil {
-		return
-	}
-	if n > c.Server.MaxBodySize {
-		return nil, values.Errorf(413, "request body too large")
-	}
-	if n == 0 {
-		if t := c.Request.Header.Get(HeaderContentType); strings.HasPrefix(strings.ToLower(t), binder.MIMEPOSTForm) {
-			b.WriteString(c.Request.URL.RawQuery)
-		}
+	c.rewindBody()
+	if c.spool == nil {
+		return bytes.NewBuffer(c.body), nil
 	}
+	b = bytes.NewBuffer(make([]byte, 0, c.spool.size))
+	_, err = b.ReadFrom(c.spool.reader())
 	return
 }
 
+// URL 按路由名称生成路径,参见 Server.URL
+func (c *Context) URL(name string, params ...any) (string, error) {
+	return c.Server.URL(name, params...)
+}
+
 func (c *Context) Error(format any) error {
 	return values.Error(format)
 }
@@ -321,34 +491,15 @@ func (c *Context) Errorf(code int32, format any, args ...any) error {
 	return values.Errorf(code, format, args...)
 }
 
+// Accept 协商响应使用的序列化方式,结果在请求内缓存,参见 Produces
 func (c *Context) Accept() binder.Binder {
 	if c.accept != nil {
 		return c.accept
 	}
-	// 栈数组 + 手动切割，避免 strings.Split 的 []string 堆分配
-	for _, header := range [2]string{
-		c.Request.Header.Get(HeaderAccept),
-		c.Request.Header.Get(HeaderContentType),
-	} {
-		for header != "" {
-			var s string
-			if i := strings.IndexByte(header, ','); i >= 0 {
-				s, header = header[:i], header[i+1:]
-			} else {
-				s, header = header, ""
-			}
-			if i := strings.IndexByte(s, ';'); i >= 0 {
-				s = s[:i]
-			}
-			s = strings.TrimSpace(s)
-			if c.Server.AcceptIgnore[s] {
-				continue
-			}
-			if c.accept = binder.Get(s); c.accept != nil {
-				return c.accept
-			}
-		}
+	b, ok := c.negotiate()
+	if !ok {
+		b = c.Server.Binder
 	}
-	c.accept = c.Server.Binder
-	return c.accept
+	c.accept = b
+	return b
 }
@@ -0,0 +1,138 @@
+package cosweb
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hwcer/cosweb/websocket"
+)
+
+// TestMultiValueParams 验证 GetStrings/GetInts 保留重复参数,单值 getter 仍返回第一个值。
+func TestMultiValueParams(t *testing.T) {
+	s := New()
+	var tags, ids, forms, headers []string
+	var nums []int
+	var tag, form string
+	s.Register("/multi", func(c *Context) any {
+		tags = c.GetStrings("tag")
+		tag = c.GetString("tag")
+		nums = c.GetInts("n", RequestDataTypeQuery)
+		forms = c.GetStrings("f", RequestDataTypeBody)
+		form = c.GetString("f")
+		ids = c.GetStrings("ids", RequestDataTypeBody)
+		headers = c.GetStrings("X-Tag", RequestDataTypeHeader)
+		return nil
+	}, http.MethodPost)
+
+	r := httptest.NewRequest(http.MethodPost, "/multi?tag=a&tag=b&n=1&n=2", strings.NewReader("f=x&f=y"))
+	r.Header.Set(HeaderContentType, "application/x-www-form-urlencoded")
+	r.Header.Add("X-Tag", "h1")
+	r.Header.Add("X-Tag", "h2")
+	s.ServeHTTP(httptest.NewRecorder(), r)
+
+	if !reflect.DeepEqual(tags, []string{"a", "b"}) || tag != "a" {
+		t.Errorf("query: got %v / %q", tags, tag)
+	}
+	if !reflect.DeepEqual(nums, []int{1, 2}) {
+		t.Errorf("GetInts: got %v", nums)
+	}
+	if !reflect.DeepEqual(forms, []string{"x", "y"}) || form != "x" {
+		t.Errorf("form: got %v / %q", forms, form)
+	}
+	if ids != nil {
+		t.Errorf("missing key: got %v, want nil", ids)
+	}
+	if !reflect.DeepEqual(headers, []string{"h1", "h2"}) {
+		t.Errorf("header: got %v", headers)
+	}
+
+	// JSON 数组按元素展开
+	r = httptest.NewRequest(http.MethodPost, "/multi", strings.NewReader(`{"ids":[1,2,3]}`))
+	r.Header.Set(HeaderContentType, "application/json")
+	s.ServeHTTP(httptest.NewRecorder(), r)
+	if !reflect.DeepEqual(ids, []string{"1", "2", "3"}) {
+		t.Errorf("json array: got %v", ids)
+	}
+}
+
+// TestUpgrade 验证握手(子协议、permessage-deflate、来源检查)以及升级后的消息收发。
+func TestUpgrade(t *testing.T) {
+	s := New()
+	s.GET("/ws", func(c *Context) any {
+		conn, err := c.Upgrade(&websocket.Upgrader{Subprotocols: []string{"v2", "v1"}, EnableCompression: true})
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		for {
+			typ, data, err := conn.ReadMessage()
+			if err != nil {
+				return nil
+			}
+			if err = conn.WriteMessage(typ, append([]byte(conn.Subprotocol()+":"), data...)); err != nil {
+				return nil
+			}
+		}
+	})
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	dial := func(origin string) (net.Conn, *http.Response) {
+		conn, err := net.Dial("tcp", ts.Listener.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+"/ws", nil)
+		req.Header.Set("Connection", "Upgrade")
+		req.Header.Set("Upgrade", "websocket")
+		req.Header.Set("Sec-WebSocket-Version", "13")
+		req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+		req.Header.Set("Sec-WebSocket-Protocol", "v1, v2")
+		req.Header.Set("Sec-WebSocket-Extensions", "permessage-deflate; client_max_window_bits")
+		if origin != "" {
+			req.Header.Set(HeaderOrigin, origin)
+		}
+		if err = req.Write(conn); err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return conn, resp
+	}
+
+	conn, resp := dial("http://evil.example.com")
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("cross origin: got %d", resp.StatusCode)
+	}
+	conn.Close()
+
+	conn, resp = dial(ts.URL)
+	defer conn.Close()
+	if resp.StatusCode != http.StatusSwitchingProtocols ||
+		resp.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" ||
+		resp.Header.Get("Sec-WebSocket-Protocol") != "v2" ||
+		!strings.HasPrefix(resp.Header.Get("Sec-WebSocket-Extensions"), "permessage-deflate") {
+		t.Fatalf("handshake: %d %v", resp.StatusCode, resp.Header)
+	}
+	client := websocket.NewConn(conn, false, websocket.WithCompression(0))
+	if err := client.WriteMessage(websocket.TextMessage, []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	typ, data, err := client.ReadMessage()
+	if err != nil || typ != websocket.TextMessage || string(data) != "v2:hello" {
+		t.Fatalf("got %d %q %v", typ, data, err)
+	}
+	_ = client.WriteClose(websocket.CloseNormalClosure, "")
+	if _, _, err = client.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+		t.Errorf("close: %v", err)
+	}
+}
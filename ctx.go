@@ -0,0 +1,108 @@
+package cosweb
+
+import (
+	"context"
+	"time"
+)
+
+// *Context 实现 context.Context,可直接传给数据库、RPC 等库:
+//
+//	rows, err := db.QueryContext(c, query)
+//	ctx, cancel := c.WithTimeout(time.Second)
+//	defer cancel()
+//
+// Deadline/Done/Err 基于请求的 context,请求结束(Context 释放)时取消;
+// Value 先查找 c.Set 写入的字符串键,再委托给 Request.Context()。
+//
+// 调用过 Deadline/Done/Err/Value 的 Context 释放后不再放回缓存池,
+// 持有它的 goroutine 只会看到已取消的状态,不会误认为复用后的新请求仍属于自己;
+// 释放时也不清空 c.Set 的值与 Request,Value 在请求结束后仍可安全读取。
+var _ context.Context = (*Context)(nil)
+
+// ctxState 请求级可取消 context,首次使用时创建
+type ctxState struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// releasedContext 已释放的 Context 使用的 context,始终处于取消状态
+var releasedContext = func() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return ctx
+}()
+
+// context 返回请求级可取消 context,并标记 Context 已逃逸,释放后不再复用
+func (c *Context) context() context.Context {
+	if s := c.ctx.Load(); s != nil {
+		return s.ctx
+	}
+	if c.Request == nil {
+		return releasedContext
+	}
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	if !c.ctx.CompareAndSwap(nil, &ctxState{ctx: ctx, cancel: cancel}) {
+		cancel()
+	}
+	return c.ctx.Load().ctx
+}
+
+// Deadline 实现 context.Context
+func (c *Context) Deadline() (time.Time, bool) {
+	return c.context().Deadline()
+}
+
+// Done 实现 context.Context,请求取消、客户端断开或请求结束时关闭
+func (c *Context) Done() <-chan struct{} {
+	return c.context().Done()
+}
+
+// Err 实现 context.Context
+func (c *Context) Err() error {
+	return c.context().Err()
+}
+
+// Value 实现 context.Context,字符串键优先返回 c.Set 写入的值;
+// 与 Deadline/Done/Err 相同,调用后 Context 释放时不再放回缓存池
+func (c *Context) Value(key any) any {
+	c.context()
+	if key == (contextKey{}) {
+		return c
+	}
+	if k, ok := key.(string); ok {
+		if store, ok := c.stores[RequestDataTypeContext]; ok && store.Has(k) {
+			return store.Get(k)
+		}
+	}
+	if c.Request == nil {
+		return nil
+	}
+	return c.Request.Context().Value(key)
+}
+
+// WithTimeout 返回带超时的子 context,请求结束时同样被取消,c.Set 写入的值仍然可见
+func (c *Context) WithTimeout(timeout time.Duration) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(c, timeout)
+}
+
+// WithoutCancel 返回请求结束后仍可使用的子 context,c.Set 写入的值保持为本次请求的值。
+// 直接使用 context.WithoutCancel(c) 时,请求内若从未调用 Deadline/Done/Err/Value,
+// Context 仍会放回缓存池,之后读取到的是复用后其他请求的值
+func (c *Context) WithoutCancel() context.Context {
+	c.context()
+	return context.WithoutCancel(c)
+}
+
+// WithDeadline 返回带截止时间的子 context,参见 WithTimeout
+func (c *Context) WithDeadline(d time.Time) (context.Context, context.CancelFunc) {
+	return context.WithDeadline(c, d)
+}
+
+// escaped 取消请求级 context,返回 Context 是否曾作为 context.Context 使用
+func (c *Context) escaped() bool {
+	if s := c.ctx.Load(); s != nil {
+		s.cancel()
+		return true
+	}
+	return false
+}
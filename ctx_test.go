@@ -0,0 +1,84 @@
+package cosweb
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type ctxKey struct{}
+
+// TestContextImplementsContext 验证 *Context 作为 context.Context 使用:Value 可见 c.Set 的值,请求结束后取消且不再复用。
+func TestContextImplementsContext(t *testing.T) {
+	s := New()
+	captured := make(chan *Context, 1)
+	s.GET("/ctx", func(c *Context) any {
+		c.Set("uid", "u1")
+		var ctx context.Context = c
+		if ctx.Value("uid") != "u1" {
+			t.Errorf("Value(uid): got %v", ctx.Value("uid"))
+		}
+		if ctx.Value(ctxKey{}) != "request" {
+			t.Errorf("Value(ctxKey): got %v", ctx.Value(ctxKey{}))
+		}
+		if c.Err() != nil {
+			t.Errorf("Err during request: %v", c.Err())
+		}
+		sub, cancel := c.WithTimeout(time.Hour)
+		defer cancel()
+		if _, ok := sub.Deadline(); !ok || sub.Value("uid") != "u1" {
+			t.Error("WithTimeout: missing deadline or value")
+		}
+		captured <- c
+		return nil
+	})
+	r := httptest.NewRequest(http.MethodGet, "/ctx", nil)
+	r = r.WithContext(context.WithValue(r.Context(), ctxKey{}, "request"))
+	s.ServeHTTP(httptest.NewRecorder(), r)
+
+	c := <-captured
+	select {
+	case <-c.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Done not closed after release")
+	}
+	for i := 0; i < 10; i++ {
+		serveTest(s, http.MethodGet, "/missing")
+	}
+	if !errors.Is(c.Err(), context.Canceled) {
+		t.Errorf("released context reused: Err = %v", c.Err())
+	}
+}
+
+// TestContextCapturedAfterRelease 请求结束后继续持有的 context 不会读取到复用 Context 的其他请求的值。
+func TestContextCapturedAfterRelease(t *testing.T) {
+	s := New()
+	var captured context.Context
+	s.GET("/without-cancel", func(c *Context) any {
+		c.Set("user", "alice")
+		captured = c.WithoutCancel()
+		return nil
+	})
+	s.GET("/with-value", func(c *Context) any {
+		c.Set("user", "alice")
+		captured = context.WithValue(c, ctxKey{}, "a")
+		_ = captured.Value("user")
+		return nil
+	})
+	s.GET("/b", func(c *Context) any {
+		c.Set("user", "bob")
+		return nil
+	})
+	for _, path := range []string{"/without-cancel", "/with-value"} {
+		serveTest(s, http.MethodGet, path)
+		for i := 0; i < 10; i++ {
+			serveTest(s, http.MethodGet, "/b")
+		}
+		if v := captured.Value("user"); v != "alice" {
+			t.Errorf("%s: Value(user) = %v, want alice", path, v)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package cosweb
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/klauspost/compress/flate"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zlib"
+	"github.com/klauspost/compress/zstd"
+)
+
+// ErrUnsupportedEncoding 请求体使用了不支持的 Content-Encoding
+var ErrUnsupportedEncoding = NewHTTPError(http.StatusUnsupportedMediaType, "unsupported content encoding")
+
+// zstdMaxWindow zstd 解压窗口上限,限制单个请求的解压内存
+const zstdMaxWindow = 8 << 20
+
+// decodeBody 按 Content-Encoding(gzip、deflate、zstd,可叠加)透明解压请求体,
+// 替换 c.Request.Body 并移除 Content-Encoding,之后 MaxBodySize 等限制作用于解压后的大小,防止压缩炸弹
+func (c *Context) decodeBody() error {
+	var encodings []string
+	for _, v := range c.Request.Header.Values(HeaderContentEncoding) {
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.ToLower(strings.TrimSpace(s)); s != "" && s != "identity" {
+				encodings = append(encodings, s)
+			}
+		}
+	}
+	if len(encodings) == 0 {
+		return nil
+	}
+	c.Request.Header.Del(HeaderContentEncoding)
+	if c.Request.Body == nil || c.Request.Body == http.NoBody || c.Request.ContentLength == 0 {
+		return nil
+	}
+	c.Request.ContentLength = -1
+	var r io.Reader = c.Request.Body
+	body := &decodedBody{closers: []io.Closer{c.Request.Body}}
+	c.Request.Body = body
+	c.decoded = body
+	// 按与编码相反的顺序解压
+	for i := len(encodings) - 1; i >= 0; i-- {
+		var err error
+		var rc io.ReadCloser
+		switch encodings[i] {
+		case "gzip", "x-gzip":
+			rc, err = gzip.NewReader(r)
+		case "deflate":
+			rc, err = newDeflateReader(r)
+		case "zstd":
+			var d *zstd.Decoder
+			if d, err = zstd.NewReader(r, zstd.WithDecoderConcurrency(1), zstd.WithDecoderLowmem(true), zstd.WithDecoderMaxWindow(zstdMaxWindow)); err == nil {
+				rc = d.IOReadCloser()
+			}
+		default:
+			err = ErrUnsupportedEncoding
+		}
+		if errors.Is(err, io.EOF) {
+			body.r = strings.NewReader("")
+			return nil
+		}
+		if err != nil {
+			var he *HTTPError
+			if !errors.As(err, &he) {
+				err = NewHTTPError(http.StatusBadRequest, "invalid %s request body: %v", encodings[i], err)
+			}
+			body.r = errReader{err: err}
+			return err
+		}
+		body.closers = append(body.closers, rc)
+		r = rc
+	}
+	body.r = r
+	return nil
+}
+
+// newDeflateReader HTTP deflate 应为 zlib 格式,部分客户端发送裸 deflate,按首部自动识别
+func newDeflateReader(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	header, err := br.Peek(2)
+	if err != nil {
+		return nil, err
+	}
+	if header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+	return flate.NewReader(br), nil
+}
+
+// decodedBody 解压后的请求体,Close 时关闭各层解压器与原始 Body
+type decodedBody struct {
+	r       io.Reader
+	closers []io.Closer
+}
+
+func (b *decodedBody) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	if err != nil && err != io.EOF {
+		var he *HTTPError
+		if !errors.As(err, &he) {
+			err = NewHTTPError(http.StatusBadRequest, "invalid compressed request body: %v", err)
+		}
+	}
+	return n, err
+}
+
+func (b *decodedBody) Close() error {
+	var err error
+	for i := len(b.closers) - 1; i >= 0; i-- {
+		if e := b.closers[i].Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// errReader 解压失败后读取请求体时返回同样的错误
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
@@ -29,6 +29,18 @@ var HTTPErrorHandler = func(c *Context, format any, args ...any) {
 	if he.Message == "" {
 		he.Message = http.StatusText(he.Code)
 	}
+	// 携带结构化明细(如校验错误)时按 Accept 序列化整个 HTTPError
+	if he.Errors != nil {
+		b := c.Accept()
+		if data, err := b.Marshal(he); err == nil {
+			c.writeContentType(ContentType(b.String()))
+			c.WriteHeader(he.Code)
+			if _, err = c.Response.Write(data); err != nil {
+				logger.Error(err)
+			}
+			return
+		}
+	}
 	c.Response.Header().Set(HeaderContentType, GetContentTypeCharset(ContentTypeTextPlain))
 	c.WriteHeader(he.Code)
 	if _, err := c.Response.Write([]byte(he.Message)); err != nil {
@@ -40,15 +52,18 @@ var HTTPErrorHandler = func(c *Context, format any, args ...any) {
 type HTTPError struct {
 	Code    int    `json:"-"`
 	Message string `json:"message"`
+	Errors  any    `json:"errors,omitempty"` //结构化错误明细,如 ValidationErrors
 }
 
 // Errors
 var (
-	ErrNotFound             = NewHTTPError(http.StatusNotFound, http.StatusText(http.StatusNotFound))
-	ErrForbidden            = NewHTTPError(http.StatusForbidden, http.StatusText(http.StatusForbidden))
-	ErrInternalServerError  = NewHTTPError(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
-	ErrInvalidCertOrKeyType = NewHTTPError(0, "invalid cert or key type, must be string or []byte")
-	ErrHandlerError         = NewHTTPError(0, "handler type error")
+	ErrNotFound              = NewHTTPError(http.StatusNotFound, http.StatusText(http.StatusNotFound))
+	ErrForbidden             = NewHTTPError(http.StatusForbidden, http.StatusText(http.StatusForbidden))
+	ErrMethodNotAllowed      = NewHTTPError(http.StatusMethodNotAllowed, http.StatusText(http.StatusMethodNotAllowed))
+	ErrInternalServerError   = NewHTTPError(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+	ErrRequestEntityTooLarge = NewHTTPError(http.StatusRequestEntityTooLarge, "request body too large")
+	ErrInvalidCertOrKeyType  = NewHTTPError(0, "invalid cert or key type, must be string or []byte")
+	ErrHandlerError          = NewHTTPError(0, "handler type error")
 
 	ErrValidatorNotRegistered = NewHTTPError(0, "validator not registered")
 	ErrRendererNotRegistered  = NewHTTPError(0, "renderer not registered")
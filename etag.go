@@ -0,0 +1,209 @@
+package cosweb
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strings"
+
+	"github.com/hwcer/cosgo/binder"
+)
+
+// ErrPreconditionFailed If-Match 与资源当前的 ETag 不符
+var ErrPreconditionFailed = NewHTTPError(http.StatusPreconditionFailed, http.StatusText(http.StatusPreconditionFailed))
+
+// ETagMode handler 响应的 ETag 生成方式
+type ETagMode int8
+
+const (
+	ETagNone   ETagMode = iota //不生成,路由未设置时使用 Server.ETag
+	ETagStrong                 //强校验 "hash",可用于 If-Match
+	ETagWeak                   //弱校验 W/"hash",只用于 If-None-Match
+	ETagOff    ETagMode = -1   //路由级关闭,覆盖 Server.ETag
+)
+
+// WithETag 设置路由的 ETag 生成方式,覆盖 Server.ETag
+func WithETag(mode ETagMode) RouteOption {
+	return func(r *route) {
+		r.etag = mode
+	}
+}
+
+// ETagMode 当前请求生效的 ETag 生成方式:路由 > Server
+func (c *Context) ETagMode() ETagMode {
+	if c.entry != nil && c.entry.etag != ETagNone {
+		return c.entry.etag
+	}
+	return c.Server.ETag
+}
+
+// ETag 按当前请求生效的方式计算 data 的 ETag,未开启时按强校验计算
+func (c *Context) ETag(data []byte) string {
+	if c.ETagMode() == ETagWeak {
+		return "W/" + strongETag(data)
+	}
+	return strongETag(data)
+}
+
+// strongETag 响应体 sha256 的前 144 位
+func strongETag(data []byte) string {
+	sum := sha256.Sum256(data)
+	return `"` + base64.RawURLEncoding.EncodeToString(sum[:18]) + `"`
+}
+
+// IfMatch 校验 PUT/PATCH/DELETE 的 If-Match 前置条件,current 为资源当前的内容,
+// 按与 handler 返回值相同的方式序列化后计算强 ETag;没有 If-Match 时直接通过,不符时返回 412。
+// 客户端的 ETag 可能来自其他表示(如 GET 时协商为 XML,PUT 时为 JSON),
+// 与当前表示不符时依次按 Produces(未声明时为 Server 的协商候选)中的其他类型序列化比较。
+// current 为 nil 表示资源不存在,此时 If-Match: * 也返回 412
+//
+//	if err := c.IfMatch(config); err != nil {
+//		return err
+//	}
+func (c *Context) IfMatch(current any) error {
+	header := c.Request.Header.Values(HeaderIfMatch)
+	if len(header) == 0 {
+		return nil
+	}
+	if current == nil {
+		return ErrPreconditionFailed
+	}
+	var tags []string
+	for _, v := range header {
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s == "*" {
+				return nil
+			} else if s != "" {
+				tags = append(tags, s)
+			}
+		}
+	}
+	var data []byte
+	switch v := current.(type) {
+	case []byte:
+		data = v
+	case *[]byte:
+		data = *v
+	}
+	if data != nil {
+		if etagMatchStrong(tags, strongETag(data)) {
+			return nil
+		}
+		return ErrPreconditionFailed
+	}
+
+	accept := c.Accept()
+	defer func() { c.accept = accept }()
+	types := c.Server.defaultProduces()
+	if c.entry != nil && len(c.entry.produces) > 0 {
+		types = c.entry.produces
+	}
+	for i := -1; i < len(types); i++ {
+		if i >= 0 {
+			if types[i] == accept.String() {
+				continue
+			}
+			if c.accept = binder.Get(types[i]); c.accept == nil {
+				continue
+			}
+		}
+		var err error
+		if h := c.handler(); h != nil {
+			data, err = h.defaultSerialize(c, current)
+		} else {
+			data, err = c.accept.Marshal(current)
+		}
+		if err != nil {
+			if i < 0 {
+				return err
+			}
+			continue
+		}
+		if etagMatchStrong(tags, strongETag(data)) {
+			return nil
+		}
+	}
+	return ErrPreconditionFailed
+}
+
+// etagEncodings 压缩中间件追加到 ETag 的编码后缀,参见 ETagWithEncoding
+var etagEncodings = []string{"gzip", "deflate", "zstd", "br"}
+
+// ETagWithEncoding 响应压缩为 encoding 后使用的 ETag:强 ETag 在引号内追加 -encoding,如 "hash-gzip",弱 ETag 不变。
+// 压缩后的字节与原响应不同,不能沿用原来的强 ETag;If-Match、If-None-Match 比较时忽略该后缀
+func ETagWithEncoding(etag, encoding string) string {
+	if len(etag) < 2 || etag[0] != '"' || etag[len(etag)-1] != '"' {
+		return etag
+	}
+	return etag[:len(etag)-1] + "-" + encoding + `"`
+}
+
+// etagEqual s 与 etag 相同,或是 etag 经 ETagWithEncoding 追加压缩后缀后的形式
+func etagEqual(s, etag string) bool {
+	if s == etag {
+		return true
+	}
+	if len(etag) < 2 || len(s) <= len(etag) || s[len(s)-1] != '"' || s[:len(etag)-1] != etag[:len(etag)-1] || s[len(etag)-1] != '-' {
+		return false
+	}
+	encoding := s[len(etag) : len(s)-1]
+	for _, e := range etagEncodings {
+		if e == encoding {
+			return true
+		}
+	}
+	return false
+}
+
+// etagMatchStrong If-Match 的强比较:弱 ETag 不会匹配
+func etagMatchStrong(tags []string, etag string) bool {
+	for _, s := range tags {
+		if !strings.HasPrefix(s, "W/") && etagEqual(s, etag) {
+			return true
+		}
+	}
+	return false
+}
+
+// notModified 开启 ETag 时为 200 响应设置 ETag(handler 已设置时沿用),
+// GET/HEAD 请求的 If-None-Match 命中时写出 304 并返回 true,调用方不再写出响应体
+func (c *Context) notModified(data []byte) bool {
+	mode := c.ETagMode()
+	if mode != ETagStrong && mode != ETagWeak {
+		return false
+	}
+	if c.Response.status != 0 && c.Response.status != http.StatusOK || !c.Response.CanWrite() {
+		return false
+	}
+	header := c.Header()
+	etag := header.Get(HeaderETag)
+	if etag == "" {
+		etag = c.ETag(data)
+		header.Set(HeaderETag, etag)
+	}
+	if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+		return false
+	}
+	if !etagMatchWeak(c.Request.Header.Values(HeaderIfNoneMatch), etag) {
+		return false
+	}
+	header.Del(HeaderContentType)
+	header.Del(HeaderContentLength)
+	c.WriteHeader(http.StatusNotModified)
+	c.Response.written = true
+	return true
+}
+
+// etagMatchWeak If-None-Match 的弱比较:忽略 W/ 前缀
+func etagMatchWeak(header []string, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, v := range header {
+		for _, s := range strings.Split(v, ",") {
+			s = strings.TrimSpace(s)
+			if s == "*" || etagEqual(strings.TrimPrefix(s, "W/"), etag) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,219 @@
+package cosweb
+
+import (
+	"net"
+	"net/netip"
+	"strings"
+)
+
+// ParseTrustedProxies 解析 Server.TrustedProxies,支持 CIDR(10.0.0.0/8)与单个 IP
+//
+//	s.TrustedProxies, err = cosweb.ParseTrustedProxies("10.0.0.0/8", "127.0.0.1", "::1")
+func ParseTrustedProxies(cidrs ...string) ([]netip.Prefix, error) {
+	r := make([]netip.Prefix, 0, len(cidrs))
+	for _, s := range cidrs {
+		s = strings.TrimSpace(s)
+		if strings.Contains(s, "/") {
+			p, err := netip.ParsePrefix(s)
+			if err != nil {
+				return nil, err
+			}
+			r = append(r, p.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(s)
+		if err != nil {
+			return nil, err
+		}
+		addr = addr.Unmap()
+		r = append(r, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return r, nil
+}
+
+// trusted 是否为可信代理
+func (srv *Server) trusted(addr netip.Addr) bool {
+	if !addr.IsValid() {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, p := range srv.TrustedProxies {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardedHop 转发链中的一跳,来自 Forwarded 的一个元素或 X-Forwarded-For 的一项
+type forwardedHop struct {
+	node  string //for=,已去除引号、方括号与端口
+	proto string
+	host  string
+}
+
+// peer 直连对端地址
+func (c *Context) peer() (string, netip.Addr) {
+	host, _, err := net.SplitHostPort(c.Request.RemoteAddr)
+	if err != nil {
+		host = c.Request.RemoteAddr
+	}
+	addr, _ := netip.ParseAddr(host)
+	return host, addr
+}
+
+// clientHop 直连对端可信时,从右向左跳过可信代理,返回第一个不可信的一跳;
+// 对端不可信或没有转发头时返回 nil
+func (c *Context) clientHop() *forwardedHop {
+	if len(c.Server.TrustedProxies) == 0 {
+		return nil
+	}
+	if _, addr := c.peer(); !c.Server.trusted(addr) {
+		return nil
+	}
+	hops := parseForwarded(c.Request.Header.Values(HeaderForwarded))
+	if len(hops) == 0 {
+		for _, v := range c.Request.Header.Values(HeaderXForwardedFor) {
+			for _, s := range strings.Split(v, ",") {
+				if s = strings.TrimSpace(s); s != "" {
+					hops = append(hops, forwardedHop{node: stripPort(s)})
+				}
+			}
+		}
+	}
+	if len(hops) == 0 {
+		return nil
+	}
+	for i := len(hops) - 1; i > 0; i-- {
+		if addr, err := netip.ParseAddr(hops[i].node); err != nil || !c.Server.trusted(addr) {
+			return &hops[i]
+		}
+	}
+	return &hops[0]
+}
+
+// RealIP 客户端真实 IP。直连对端属于 Server.TrustedProxies 时,按 Forwarded(RFC 7239)
+// 或 X-Forwarded-For 从右向左跳过可信代理,取第一个不可信的地址;否则返回直连对端地址,转发头被忽略。
+func (c *Context) RealIP() string {
+	if hop := c.clientHop(); hop != nil {
+		return hop.node
+	}
+	host, addr := c.peer()
+	if c.Server.trusted(addr) {
+		if ip := strings.TrimSpace(c.Request.Header.Get(HeaderXRealIP)); ip != "" {
+			return ip
+		}
+	}
+	return host
+}
+
+// Scheme 请求协议 http/https,直连对端可信时才使用 Forwarded proto= 与 X-Forwarded-Proto 等转发头
+func (c *Context) Scheme() string {
+	// Can't use `r.Request.URL.Protocol`
+	// See: https://groups.google.com/forum/#!topic/golang-nuts/pMUkBlQBDF0
+	if c.Request.TLS != nil {
+		return "https"
+	}
+	if _, addr := c.peer(); !c.Server.trusted(addr) {
+		return "http"
+	}
+	if hop := c.clientHop(); hop != nil && hop.proto != "" {
+		return strings.ToLower(hop.proto)
+	}
+	header := c.Request.Header
+	if scheme := firstValue(header.Get(HeaderXForwardedProto)); scheme != "" {
+		return strings.ToLower(scheme)
+	}
+	if scheme := firstValue(header.Get(HeaderXForwardedProtocol)); scheme != "" {
+		return strings.ToLower(scheme)
+	}
+	if ssl := header.Get(HeaderXForwardedSsl); ssl == "on" {
+		return "https"
+	}
+	if scheme := header.Get(HeaderXUrlScheme); scheme != "" {
+		return strings.ToLower(scheme)
+	}
+	return "http"
+}
+
+// Host 客户端请求的域名(可含端口),直连对端可信时才使用 Forwarded host= 与 X-Forwarded-Host
+func (c *Context) Host() string {
+	if _, addr := c.peer(); c.Server.trusted(addr) {
+		if hop := c.clientHop(); hop != nil && hop.host != "" {
+			return hop.host
+		}
+		if host := firstValue(c.Request.Header.Get(HeaderXForwardedHost)); host != "" {
+			return host
+		}
+	}
+	return c.Request.Host
+}
+
+// parseForwarded 解析 RFC 7239 Forwarded 头,多个头按出现顺序合并
+func parseForwarded(values []string) []forwardedHop {
+	var hops []forwardedHop
+	for _, v := range values {
+		for _, elem := range splitQuoted(v, ',') {
+			var hop forwardedHop
+			for _, pair := range splitQuoted(elem, ';') {
+				key, value, ok := strings.Cut(pair, "=")
+				if !ok {
+					continue
+				}
+				value = strings.TrimSpace(value)
+				if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+					value = strings.ReplaceAll(value[1:len(value)-1], `\"`, `"`)
+				}
+				switch strings.ToLower(strings.TrimSpace(key)) {
+				case "for":
+					hop.node = stripPort(value)
+				case "proto":
+					hop.proto = value
+				case "host":
+					hop.host = value
+				}
+			}
+			if hop.node != "" {
+				hops = append(hops, hop)
+			}
+		}
+	}
+	return hops
+}
+
+// splitQuoted 按 sep 分割,忽略引号内的分隔符
+func splitQuoted(s string, sep byte) []string {
+	var r []string
+	quoted := false
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			quoted = !quoted
+		case '\\':
+			i++
+		case sep:
+			if !quoted {
+				r = append(r, strings.TrimSpace(s[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	return append(r, strings.TrimSpace(s[start:]))
+}
+
+// stripPort 去除地址中的端口与 IPv6 方括号:[2001:db8::1]:4711 → 2001:db8::1, 192.0.2.1:80 → 192.0.2.1
+func stripPort(s string) string {
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		return host
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")
+}
+
+// firstValue 逗号分隔的第一个值
+func firstValue(s string) string {
+	if i := strings.IndexByte(s, ','); i >= 0 {
+		s = s[:i]
+	}
+	return strings.TrimSpace(s)
+}
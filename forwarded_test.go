@@ -0,0 +1,49 @@
+package cosweb
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestTrustedProxies 验证只有可信代理转发的头才会被采用,并从右向左跳过可信代理。
+func TestTrustedProxies(t *testing.T) {
+	s := New()
+	var err error
+	if s.TrustedProxies, err = ParseTrustedProxies("10.0.0.0/8", "::1"); err != nil {
+		t.Fatal(err)
+	}
+	var ip, scheme, host string
+	s.GET("/ip", func(c *Context) any {
+		ip, scheme, host = c.RealIP(), c.Scheme(), c.Host()
+		return nil
+	})
+	cases := []struct {
+		name   string
+		remote string
+		header map[string]string
+		ip     string
+		scheme string
+		host   string
+	}{
+		{"untrusted peer", "203.0.113.9:1234", map[string]string{"X-Forwarded-For": "1.1.1.1", "X-Forwarded-Proto": "https", "X-Forwarded-Host": "evil"},
+			"203.0.113.9", "http", "example.com"},
+		{"xff right to left", "10.0.0.1:1234", map[string]string{"X-Forwarded-For": "6.6.6.6, 1.2.3.4, 10.0.0.2", "X-Forwarded-Proto": "https", "X-Forwarded-Host": "api.example.com"},
+			"1.2.3.4", "https", "api.example.com"},
+		{"all trusted", "10.0.0.1:1234", map[string]string{"X-Forwarded-For": "10.0.0.3, 10.0.0.2"},
+			"10.0.0.3", "http", "example.com"},
+		{"forwarded", "[::1]:1234", map[string]string{"Forwarded": `for=192.0.2.60;proto=https;host=a.example.com, for="[2001:db8::17]:4711";proto=http, for=10.1.1.1`},
+			"2001:db8::17", "http", "example.com"},
+	}
+	for _, tt := range cases {
+		r := httptest.NewRequest(http.MethodGet, "http://example.com/ip", nil)
+		r.RemoteAddr = tt.remote
+		for k, v := range tt.header {
+			r.Header.Set(k, v)
+		}
+		s.ServeHTTP(httptest.NewRecorder(), r)
+		if ip != tt.ip || scheme != tt.scheme || host != tt.host {
+			t.Errorf("%s: got %s %s %s, want %s %s %s", tt.name, ip, scheme, host, tt.ip, tt.scheme, tt.host)
+		}
+	}
+}
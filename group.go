@@ -0,0 +1,88 @@
+package cosweb
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/hwcer/cosgo/registry"
+)
+
+// Group 路由分组,组内路由共享 URL 前缀与中间件。
+// 分组中间件作为路由级中间件保存,执行顺序: 全局 → 服务(Handler) → 外层分组 → 内层分组 → handler。
+//
+//	api := s.Group("/api", auth)
+//	admin := api.Group("/v1/admin", audit)
+//	admin.GET("/users", listUsers) // GET /api/v1/admin/users, 依次执行 auth, audit
+type Group struct {
+	srv        *Server
+	prefix     string
+	middleware []MiddlewareFunc
+}
+
+// Group 创建路由分组
+func (srv *Server) Group(prefix string, middleware ...MiddlewareFunc) *Group {
+	return &Group{srv: srv, prefix: registry.Join(prefix), middleware: compactMiddleware(nil, middleware)}
+}
+
+// Group 创建嵌套分组,继承当前分组的前缀和中间件
+func (g *Group) Group(prefix string, middleware ...MiddlewareFunc) *Group {
+	return &Group{srv: g.srv, prefix: g.path(prefix), middleware: compactMiddleware(g.middleware, middleware)}
+}
+
+// Prefix 分组的完整 URL 前缀
+func (g *Group) Prefix() string {
+	return g.prefix
+}
+
+// GET 在分组内注册 GET 路由
+func (g *Group) GET(path string, h func(*Context) any, options ...RouteOption) {
+	g.srv.register(g.path(path), h, routeOptions(http.MethodGet, options), g.middleware)
+}
+
+// POST 在分组内注册 POST 路由
+func (g *Group) POST(path string, h func(*Context) any, options ...RouteOption) {
+	g.srv.register(g.path(path), h, routeOptions(http.MethodPost, options), g.middleware)
+}
+
+// Register 在分组内注册路由,options 参见 Server.Register
+func (g *Group) Register(route string, handler func(*Context) any, options ...any) {
+	g.srv.register(g.path(route), handler, anyOptions(route, options), g.middleware)
+}
+
+// Route 在分组内使用路由选项注册路由,参见 Server.Route
+func (g *Group) Route(path string, handler func(*Context) any, options ...RouteOption) {
+	g.srv.register(g.path(path), handler, options, g.middleware)
+}
+
+// Static 在分组内注册静态文件服务,参见 Server.Static
+func (g *Group) Static(prefix, root string, method ...string) *Static {
+	static := NewStatic(root)
+	if len(method) == 0 {
+		method = []string{http.MethodGet, http.MethodHead}
+	}
+	g.srv.register(wildcardRoute(g.path(prefix)), static.Handle, methodOptions(method), g.middleware)
+	return static
+}
+
+// Proxy 在分组内注册反向代理,参见 Server.Proxy
+func (g *Group) Proxy(prefix, address string, method ...string) *Proxy {
+	proxy := NewProxy(address)
+	g.srv.register(wildcardRoute(g.path(prefix)), proxy.Handle, methodOptions(method), g.middleware)
+	return proxy
+}
+
+func (g *Group) path(p string) string {
+	return registry.Join(strings.TrimRight(g.prefix, "/") + "/" + strings.TrimLeft(p, "/"))
+}
+
+// compactMiddleware 合并父级与本级中间件,始终返回新切片,避免嵌套分组之间共享底层数组
+func compactMiddleware(parent, middleware []MiddlewareFunc) []MiddlewareFunc {
+	r := make([]MiddlewareFunc, 0, len(parent)+len(middleware))
+	r = append(r, parent...)
+	for _, m := range middleware {
+		if m != nil {
+			r = append(r, m)
+		}
+	}
+	return r
+}
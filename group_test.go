@@ -0,0 +1,164 @@
+package cosweb
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func orderMiddleware(order *[]string, name string) MiddlewareFunc {
+	return func(c *Context, next Next) error {
+		*order = append(*order, name)
+		return next()
+	}
+}
+
+// TestGroupNestedMiddlewareOrder 验证嵌套分组继承前缀,中间件按 全局 → 服务 → 外层 → 内层 的顺序执行。
+func TestGroupNestedMiddlewareOrder(t *testing.T) {
+	s := New()
+	var order []string
+	s.Use(orderMiddleware(&order, "global"))
+	s.Handler().Use(orderMiddleware(&order, "service"))
+	api := s.Group("/api", orderMiddleware(&order, "api"))
+	admin := api.Group("/v1/admin", orderMiddleware(&order, "admin"))
+	admin.GET("/users", func(c *Context) any {
+		order = append(order, "handler")
+		return nil
+	})
+	api.GET("/ping", func(c *Context) any {
+		order = append(order, "ping")
+		return nil
+	})
+	if admin.Prefix() != "/api/v1/admin" {
+		t.Fatalf("unexpected prefix %q", admin.Prefix())
+	}
+
+	ts := newTestServer(t, s)
+	cases := []struct {
+		path string
+		want string
+	}{
+		{"/api/v1/admin/users", "global,service,api,admin,handler"},
+		{"/api/ping", "global,service,api,ping"},
+	}
+	for _, tt := range cases {
+		order = order[:0]
+		resp, err := http.Get(ts.URL + tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if got := strings.Join(order, ","); got != tt.want {
+			t.Errorf("GET %s: got %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+// TestGroupMiddlewareIsolation 验证兄弟分组与根路由互不继承中间件。
+func TestGroupMiddlewareIsolation(t *testing.T) {
+	s := New()
+	deny := func(c *Context, next Next) error {
+		return ErrForbidden
+	}
+	s.Group("/admin", deny).GET("/x", func(c *Context) any { return "admin" })
+	s.Group("/public").GET("/x", func(c *Context) any {
+		_ = c.String("public")
+		return nil
+	})
+
+	ts := newTestServer(t, s)
+	resp, err := http.Get(ts.URL + "/admin/x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("/admin/x: expected 403, got %d", resp.StatusCode)
+	}
+
+	resp, err = http.Get(ts.URL + "/public/x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "public" {
+		t.Errorf("/public/x: expected 'public', got %q (status %d)", body, resp.StatusCode)
+	}
+}
+
+// TestRouteMiddleware 验证路由级中间件排在分组中间件之后,且只作用于所属路由。
+func TestRouteMiddleware(t *testing.T) {
+	s := New()
+	var order []string
+	s.Use(orderMiddleware(&order, "global"))
+	g := s.Group("/g", orderMiddleware(&order, "group"))
+	g.Register("/a", func(c *Context) any {
+		order = append(order, "a")
+		return nil
+	}, WithMethods(http.MethodGet), WithMiddleware(orderMiddleware(&order, "route")))
+	g.GET("/b", func(c *Context) any {
+		order = append(order, "b")
+		return nil
+	})
+	s.Register("/c", func(c *Context) any {
+		order = append(order, "c")
+		return nil
+	}, []string{http.MethodGet}, WithMiddleware(orderMiddleware(&order, "route-c")))
+
+	ts := newTestServer(t, s)
+	cases := []struct {
+		path string
+		want string
+	}{
+		{"/g/a", "global,group,route,a"},
+		{"/g/b", "global,group,b"},
+		{"/c", "global,route-c,c"},
+	}
+	for _, tt := range cases {
+		order = order[:0]
+		resp, err := http.Get(ts.URL + tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if got := strings.Join(order, ","); got != tt.want {
+			t.Errorf("GET %s: got %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+// TestRouteURL 验证按名称反向生成路径。
+func TestRouteURL(t *testing.T) {
+	s := New()
+	h := func(c *Context) any { return nil }
+	s.GET("/user/:id", h, WithName("user"))
+	s.Group("/files").Route("/*", h, WithName("file"))
+	s.GET("/", h, WithName("home"))
+
+	cases := []struct {
+		name   string
+		params []any
+		want   string
+	}{
+		{"user", []any{"id", 42}, "/user/42"},
+		{"user", []any{"id", "a b", "tab", "info"}, "/user/a%20b?tab=info"},
+		{"file", []any{"*", "img/a.png"}, "/files/img/a.png"},
+		{"home", nil, "/"},
+	}
+	for _, tt := range cases {
+		got, err := s.URL(tt.name, tt.params...)
+		if err != nil {
+			t.Errorf("URL(%q): %v", tt.name, err)
+		} else if got != tt.want {
+			t.Errorf("URL(%q): got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+	if _, err := s.URL("user"); err == nil {
+		t.Errorf("URL without required param should fail")
+	}
+	if _, err := s.URL("missing"); err == nil {
+		t.Errorf("URL with unknown name should fail")
+	}
+}
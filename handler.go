@@ -20,12 +20,16 @@ type HandlerFilter func(node *registry.Node) bool
 type MiddlewareFunc func(*Context, Next) error
 type HandlerSerialize func(c *Context, reply any) ([]byte, error)
 
+var defaultHandler = &Handler{}
+
 type Handler struct {
 	//method     []string
-	caller     HandlerCaller //自定义全局消息调用
-	filter     HandlerFilter
-	serialize  HandlerSerialize //消息序列化封装
-	middleware []MiddlewareFunc
+	caller       HandlerCaller //自定义全局消息调用
+	filter       HandlerFilter
+	serialize    HandlerSerialize //消息序列化封装
+	middleware   []MiddlewareFunc
+	maxBodySize  int64 //服务级最大请求体大小,覆盖 Server.MaxBodySize
+	maxCacheSize int64
 }
 
 // Use middleware
@@ -79,7 +83,9 @@ func (h *Handler) handle(node *registry.Node, c *Context) (reply any, err error)
 	if h.caller != nil {
 		return h.caller(node, c)
 	}
-	if node.IsFunc() {
+	if c.entry != nil {
+		reply = c.entry.handler(c)
+	} else if node.IsFunc() {
 		f, _ := node.Method().(func(*Context) any)
 		reply = f(c)
 	} else if s, ok := node.Binder().(handleCaller); ok {
@@ -95,28 +101,40 @@ func (h *Handler) handle(node *registry.Node, c *Context) (reply any, err error)
 }
 
 func (h *Handler) write(c *Context, reply any) (err error) {
+	return h.writeStatus(c, 0, reply)
+}
+
+// writeStatus 序列化 reply 并写出,code 非 0 时在写入响应体前设置状态码
+func (h *Handler) writeStatus(c *Context, code int, reply any) (err error) {
 	if !c.Response.CanWrite() {
 		return nil
 	}
 	b := c.Accept()
+	var data []byte
 	switch v := reply.(type) {
 	case []byte:
-		return c.Bytes(ContentType(b.String()), v)
+		data = v
 	case *[]byte:
-		return c.Bytes(ContentType(b.String()), *v)
+		data = *v
 	default:
-		var data []byte
 		if h.serialize != nil {
 			data, err = h.serialize(c, reply)
 		} else {
 			data, err = h.defaultSerialize(c, reply)
 		}
-		if err == nil {
-			return c.Bytes(ContentType(b.String()), data)
-		} else {
+		if err != nil {
 			return err
 		}
 	}
+	if code == 0 && c.notModified(data) {
+		return nil
+	}
+	c.writeContentType(ContentType(b.String()))
+	if code != 0 {
+		c.WriteHeader(code)
+	}
+	_, err = c.Response.Write(data)
+	return
 }
 
 func (h *Handler) defaultSerialize(c *Context, reply any) ([]byte, error) {
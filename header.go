@@ -1,6 +1,11 @@
 package cosweb
 
-import "github.com/hwcer/cosgo/binder"
+import (
+	"net/http"
+	"strings"
+
+	"github.com/hwcer/cosgo/binder"
+)
 
 var Charset = "UTF-8"
 
@@ -12,19 +17,27 @@ const (
 	HeaderAcceptEncoding      = "Accept-Encoding"
 	HeaderAllow               = "Allow"
 	HeaderAuthorization       = "Authorization"
+	HeaderCacheControl        = "Cache-Control"
 	HeaderContentDisposition  = "Content-Disposition"
+	HeaderConnection          = "Connection"
 	HeaderContentEncoding     = "Content-Encoding"
 	HeaderContentLength       = "Content-Length"
 	HeaderContentType         = "Content-Type"
 	HeaderCookie              = "Cookie"
 	HeaderSetCookie           = "Set-Cookie"
+	HeaderETag                = "ETag"
+	HeaderIfMatch             = "If-Match"
 	HeaderIfModifiedSince     = "If-Modified-Since"
+	HeaderIfNoneMatch         = "If-None-Match"
 	HeaderLastModified        = "Last-Modified"
+	HeaderLastEventID         = "Last-Event-ID"
 	HeaderLocation            = "Location"
 	HeaderUpgrade             = "Upgrade"
 	HeaderVary                = "Vary"
 	HeaderWWWAuthenticate     = "WWW-Authenticate"
+	HeaderForwarded           = "Forwarded"
 	HeaderXForwardedFor       = "X-Forwarded-For"
+	HeaderXForwardedHost      = "X-Forwarded-Host"
 	HeaderXForwardedProto     = "X-Forwarded-Proto"
 	HeaderXForwardedProtocol  = "X-Forwarded-Protocol"
 	HeaderXForwardedSsl       = "X-Forwarded-Ssl"
@@ -62,6 +75,7 @@ const (
 	ContentTypeTextHTML              ContentType = "text/html"
 	ContentTypeTextPlain             ContentType = "text/plain"
 	ContentTypeTextXML               ContentType = "text/xml"
+	ContentTypeTextEventStream       ContentType = "text/event-stream"
 	ContentTypeApplicationJS         ContentType = "application/javascript"
 	ContentTypeApplicationXML        ContentType = "application/xml"
 	ContentTypeApplicationJSON       ContentType = "application/json"
@@ -80,6 +94,24 @@ func GetContentTypeCharset(contentType ContentType) string {
 	return string(contentType) + "; charset=" + Charset
 }
 
+// AddVary 向响应头 Vary 追加 field,已存在(不区分大小写)或为 * 时跳过,避免多个中间件重复追加
+func AddVary(h http.Header, field string) {
+	for _, v := range h[HeaderVary] {
+		for v != "" {
+			var s string
+			if i := strings.IndexByte(v, ','); i >= 0 {
+				s, v = v[:i], v[i+1:]
+			} else {
+				s, v = v, ""
+			}
+			if s = strings.TrimSpace(s); s == "*" || strings.EqualFold(s, field) {
+				return
+			}
+		}
+	}
+	h.Add(HeaderVary, field)
+}
+
 func init() {
 	//默认非静态文件和模版引擎的情况下，浏览器请求返回的数据使用JSON序列化
 	ct := string(ContentTypeTextHTML)
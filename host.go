@@ -0,0 +1,115 @@
+package cosweb
+
+import (
+	"net"
+	"strings"
+
+	"github.com/hwcer/cosgo/registry"
+)
+
+// HostParamSubdomain 通配虚拟主机(*.example.com)匹配到的子域名,以路径参数形式通过 c.Get 获取
+const HostParamSubdomain = "subdomain"
+
+// virtualHosts 虚拟主机表,约定在启动阶段注册,之后只读
+type virtualHosts struct {
+	exact    map[string]*Server
+	wildcard []wildcardHost //按后缀长度降序,优先匹配更具体的域名
+}
+
+type wildcardHost struct {
+	suffix string // .example.com
+	server *Server
+}
+
+// Host 返回按域名划分的虚拟主机,拥有独立的 Registry、中间件和路由,
+// ServeHTTP 在路由查找前按 Request.Host 选择虚拟主机,未匹配的请求由当前 Server 处理。
+//
+// pattern 为完整域名(admin.example.com)或通配域名(*.example.com),不含端口;
+// 通配域名匹配到的子域名可通过 c.GetString(HostParamSubdomain) 获取。
+// 虚拟主机先执行当前 Server 的全局中间件,再执行自身中间件;
+// Binder、MaxBodySize 等配置在调用 Host 时从当前 Server 复制,之后互不影响。
+//
+//	admin := s.Host("admin.example.com")
+//	admin.Use(auth)
+//	admin.GET("/", dashboard)
+//	portal := s.Host("*.example.com")
+//	portal.GET("/", func(c *cosweb.Context) any { return c.GetString(cosweb.HostParamSubdomain) })
+func (srv *Server) Host(pattern string) *Server {
+	if srv.parent != nil {
+		return srv.parent.Host(pattern)
+	}
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+	if strings.HasPrefix(pattern, "*.") {
+		suffix := pattern[1:]
+		for _, h := range srv.hosts.wildcard {
+			if h.suffix == suffix {
+				return h.server
+			}
+		}
+		vh := srv.fork()
+		i := 0
+		for i < len(srv.hosts.wildcard) && len(srv.hosts.wildcard[i].suffix) >= len(suffix) {
+			i++
+		}
+		srv.hosts.wildcard = append(srv.hosts.wildcard, wildcardHost{})
+		copy(srv.hosts.wildcard[i+1:], srv.hosts.wildcard[i:])
+		srv.hosts.wildcard[i] = wildcardHost{suffix: suffix, server: vh}
+		return vh
+	}
+	if vh, ok := srv.hosts.exact[pattern]; ok {
+		return vh
+	}
+	if srv.hosts.exact == nil {
+		srv.hosts.exact = make(map[string]*Server)
+	}
+	vh := srv.fork()
+	srv.hosts.exact[pattern] = vh
+	return vh
+}
+
+// virtualHost 按请求 Host 查找虚拟主机,返回虚拟主机和通配匹配到的子域名
+func (srv *Server) virtualHost(host string) (*Server, string) {
+	if len(srv.hosts.exact) == 0 && len(srv.hosts.wildcard) == 0 {
+		return nil, ""
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if vh, ok := srv.hosts.exact[host]; ok {
+		return vh, ""
+	}
+	for _, h := range srv.hosts.wildcard {
+		if len(host) > len(h.suffix) && strings.HasSuffix(host, h.suffix) {
+			return h.server, host[:len(host)-len(h.suffix)]
+		}
+	}
+	return nil, ""
+}
+
+// fork 创建虚拟主机使用的 Server,复制当前配置,Registry、中间件与路由表独立
+func (srv *Server) fork() *Server {
+	s := &Server{
+		Binder:          srv.Binder,
+		Render:          srv.Render,
+		Validator:       srv.Validator,
+		Server:          srv.Server,
+		Registry:        registry.New(),
+		AcceptIgnore:    srv.AcceptIgnore,
+		RequestDataType: srv.RequestDataType,
+		MaxBodySize:     srv.MaxBodySize,
+		MaxCacheSize:    srv.MaxCacheSize,
+		Multipart:       srv.Multipart,
+		AutoOptions:     srv.AutoOptions,
+		PathPolicy:      srv.PathPolicy,
+		TrustedProxies:  srv.TrustedProxies,
+		ETag:            srv.ETag,
+		routes:          make(map[*registry.Node]*route),
+		names:           make(map[string]*route),
+		parent:          srv,
+	}
+	s.pool.New = func() any {
+		return NewContext(s)
+	}
+	return s
+}
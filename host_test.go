@@ -0,0 +1,64 @@
+package cosweb
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestVirtualHost 验证按 Host 选择虚拟主机、通配子域名参数及父级全局中间件。
+func TestVirtualHost(t *testing.T) {
+	s := New()
+	var global int
+	s.Use(func(c *Context, next Next) error {
+		global++
+		return next()
+	})
+	s.GET("/", func(c *Context) any {
+		_ = c.String("main")
+		return nil
+	})
+	admin := s.Host("admin.example.com")
+	admin.Use(func(c *Context, next Next) error {
+		c.Header().Set("X-Admin", "1")
+		return next()
+	})
+	admin.GET("/", func(c *Context) any {
+		_ = c.String("admin")
+		return nil
+	})
+	portal := s.Host("*.example.com")
+	portal.GET("/", func(c *Context) any {
+		_ = c.String("portal:" + c.GetString(HostParamSubdomain))
+		return nil
+	})
+	if s.Host("*.example.com") != portal {
+		t.Fatalf("Host should return the existing virtual host")
+	}
+
+	cases := []struct {
+		host  string
+		want  string
+		admin bool
+	}{
+		{"example.com", "main", false},
+		{"admin.example.com:8080", "admin", true},
+		{"Game1.Example.com", "portal:game1", false},
+		{"other.org", "main", false},
+	}
+	for _, tt := range cases {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Host = tt.host
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		if w.Body.String() != tt.want {
+			t.Errorf("host %s: got %q, want %q", tt.host, w.Body.String(), tt.want)
+		}
+		if got := w.Header().Get("X-Admin") == "1"; got != tt.admin {
+			t.Errorf("host %s: admin middleware ran=%v, want %v", tt.host, got, tt.admin)
+		}
+	}
+	if global != len(cases) {
+		t.Errorf("global middleware should run for every host, ran %d times", global)
+	}
+}
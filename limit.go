@@ -0,0 +1,94 @@
+package cosweb
+
+import (
+	"io"
+)
+
+// maxDrainSize 请求体超限时最多继续读取并丢弃的字节数,超过后由 Connection: close 关闭连接
+const maxDrainSize = 256 << 10
+
+// WithMaxBodySize 设置路由的最大请求体大小,覆盖 Handler 与 Server.MaxBodySize
+func WithMaxBodySize(n int64) RouteOption {
+	return func(r *route) {
+		r.maxBodySize = n
+	}
+}
+
+// WithMaxCacheSize 设置路由的请求体缓存上限,覆盖 Handler 与 Server.MaxCacheSize
+func WithMaxCacheSize(n int64) RouteOption {
+	return func(r *route) {
+		r.maxCacheSize = n
+	}
+}
+
+// SetMaxBodySize 设置服务内所有路由的最大请求体大小,覆盖 Server.MaxBodySize
+func (h *Handler) SetMaxBodySize(n int64) {
+	h.maxBodySize = n
+}
+
+// SetMaxCacheSize 设置服务内所有路由的请求体缓存上限,覆盖 Server.MaxCacheSize
+func (h *Handler) SetMaxCacheSize(n int64) {
+	h.maxCacheSize = n
+}
+
+// SetMaxBodySize 设置当前请求的最大请求体大小,优先级最高,需在读取请求体之前调用
+func (c *Context) SetMaxBodySize(n int64) {
+	c.maxBodySize = n
+}
+
+// SetMaxCacheSize 设置当前请求的请求体缓存上限,需在读取请求体之前调用
+func (c *Context) SetMaxCacheSize(n int64) {
+	c.maxCacheSize = n
+}
+
+// MaxBodySize 当前请求生效的最大请求体大小:请求 > 路由 > Handler > Server
+func (c *Context) MaxBodySize() int64 {
+	return c.bodyLimit(c.Server.MaxBodySize)
+}
+
+// bodyLimit 请求、路由、Handler 均未设置时使用 def
+func (c *Context) bodyLimit(def int64) int64 {
+	if c.maxBodySize > 0 {
+		return c.maxBodySize
+	}
+	if c.entry != nil && c.entry.maxBodySize > 0 {
+		return c.entry.maxBodySize
+	}
+	if h := c.handler(); h != nil && h.maxBodySize > 0 {
+		return h.maxBodySize
+	}
+	return def
+}
+
+// MaxCacheSize 当前请求生效的请求体缓存上限:请求 > 路由 > Handler > Server
+func (c *Context) MaxCacheSize() int64 {
+	if c.maxCacheSize > 0 {
+		return c.maxCacheSize
+	}
+	if c.entry != nil && c.entry.maxCacheSize > 0 {
+		return c.entry.maxCacheSize
+	}
+	if h := c.handler(); h != nil && h.maxCacheSize > 0 {
+		return h.maxCacheSize
+	}
+	return c.Server.MaxCacheSize
+}
+
+// handler 当前路由节点所属服务的 Handler
+func (c *Context) handler() *Handler {
+	if c.node == nil {
+		return nil
+	}
+	h, _ := c.node.Handler().(*Handler)
+	return h
+}
+
+// bodyTooLarge 请求体超限:设置 Connection: close 并丢弃少量剩余数据,
+// 让仍在发送的客户端能够读到 413 响应,剩余过多时由服务器关闭连接
+func (c *Context) bodyTooLarge() error {
+	c.Header().Set(HeaderConnection, "close")
+	if c.Request.Body != nil {
+		_, _ = io.CopyN(io.Discard, c.Request.Body, maxDrainSize)
+	}
+	return ErrRequestEntityTooLarge
+}
@@ -0,0 +1,334 @@
+package middleware
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/hwcer/cosweb"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zlib"
+	"github.com/klauspost/compress/zstd"
+)
+
+/*响应压缩
+compress := middleware.NewCompress()
+compress.Exclude("application/x-custom-packed")
+cosweb.Use(compress.Middleware)
+*/
+
+const (
+	EncodingGzip    = "gzip"
+	EncodingDeflate = "deflate"
+	EncodingZstd    = "zstd"
+)
+
+// defaultExcludeTypes 本身已压缩或需要逐条推送的类型,不再压缩;以 / 结尾表示整个大类
+var defaultExcludeTypes = []string{
+	"image/", "video/", "audio/",
+	"application/zip", "application/gzip", "application/x-gzip", "application/zstd",
+	"application/x-7z-compressed", "application/x-rar-compressed", "application/x-bzip2",
+	"application/pdf", "application/octet-stream",
+	"font/woff", "font/woff2",
+	"text/event-stream",
+}
+
+// encoder gzip.Writer、zlib.Writer、zstd.Encoder 的公共方法
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+type Compress struct {
+	Level     int      //压缩级别,0 使用各算法默认级别;gzip/deflate 为 1-9,zstd 为 1-22
+	MinLength int      //小于该长度的响应不压缩,默认 1024
+	encodings []string //服务器偏好顺序,Accept-Encoding q 值相同时按此顺序选择
+	exclude   []string
+	pools     map[string]*sync.Pool
+	once      sync.Once
+}
+
+// NewCompress 创建压缩中间件,encodings 为空时按 zstd、gzip、deflate 的顺序协商
+func NewCompress(encodings ...string) *Compress {
+	if len(encodings) == 0 {
+		encodings = []string{EncodingZstd, EncodingGzip, EncodingDeflate}
+	}
+	return &Compress{
+		MinLength: 1024,
+		encodings: encodings,
+		exclude:   append([]string(nil), defaultExcludeTypes...),
+	}
+}
+
+// Exclude 追加不压缩的 Content-Type,以 / 结尾时匹配整个大类,如 "image/"
+func (this *Compress) Exclude(types ...string) {
+	for _, t := range types {
+		this.exclude = append(this.exclude, strings.ToLower(t))
+	}
+}
+
+func (this *Compress) Middleware(c *cosweb.Context, next cosweb.Next) error {
+	if c.Request.Method == http.MethodHead || c.Request.Header.Get(cosweb.HeaderUpgrade) != "" {
+		return next()
+	}
+	cosweb.AddVary(c.Header(), cosweb.HeaderAcceptEncoding)
+	encoding := this.negotiate(c.Request.Header.Values(cosweb.HeaderAcceptEncoding))
+	if encoding == "" {
+		return next()
+	}
+	this.once.Do(this.init)
+	w := &compressWriter{ResponseWriter: c.Response.ResponseWriter, compress: this, encoding: encoding}
+	c.Response.ResponseWriter = w
+	defer func() {
+		w.Close()
+		if c.Response != nil {
+			c.Response.ResponseWriter = w.ResponseWriter
+		}
+	}()
+	return next()
+}
+
+func (this *Compress) init() {
+	this.pools = make(map[string]*sync.Pool, len(this.encodings))
+	for _, e := range this.encodings {
+		var f func() any
+		switch e {
+		case EncodingGzip:
+			f = func() any {
+				w, err := gzip.NewWriterLevel(io.Discard, this.level(gzip.DefaultCompression))
+				if err != nil {
+					w = gzip.NewWriter(io.Discard)
+				}
+				return w
+			}
+		case EncodingDeflate:
+			f = func() any {
+				w, err := zlib.NewWriterLevel(io.Discard, this.level(zlib.DefaultCompression))
+				if err != nil {
+					w = zlib.NewWriter(io.Discard)
+				}
+				return w
+			}
+		case EncodingZstd:
+			level := zstd.SpeedDefault
+			if this.Level > 0 {
+				level = zstd.EncoderLevelFromZstd(this.Level)
+			}
+			f = func() any {
+				w, _ := zstd.NewWriter(io.Discard, zstd.WithEncoderLevel(level), zstd.WithEncoderConcurrency(1))
+				return w
+			}
+		default:
+			continue
+		}
+		this.pools[e] = &sync.Pool{New: f}
+	}
+}
+
+func (this *Compress) level(def int) int {
+	if this.Level != 0 {
+		return this.Level
+	}
+	return def
+}
+
+// negotiate 按 Accept-Encoding 的 q 值选择编码,q 值相同时按服务器偏好顺序,没有可用编码时返回空
+func (this *Compress) negotiate(accept []string) string {
+	if len(accept) == 0 {
+		return ""
+	}
+	q := map[string]float64{}
+	for _, v := range accept {
+		for _, s := range strings.Split(v, ",") {
+			name, params, _ := strings.Cut(s, ";")
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name == "" {
+				continue
+			}
+			weight := 1.0
+			if k, v, ok := strings.Cut(params, "="); ok && strings.EqualFold(strings.TrimSpace(k), "q") {
+				if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+					weight = f
+				}
+			}
+			q[name] = weight
+		}
+	}
+	best, bestQ := "", 0.0
+	for _, e := range this.encodings {
+		w, ok := q[e]
+		if !ok {
+			if w, ok = q["*"]; !ok {
+				continue
+			}
+		}
+		if w > bestQ {
+			best, bestQ = e, w
+		}
+	}
+	return best
+}
+
+// excluded Content-Type 是否在排除列表中
+func (this *Compress) excluded(contentType string) bool {
+	t, _, _ := strings.Cut(contentType, ";")
+	t = strings.ToLower(strings.TrimSpace(t))
+	for _, e := range this.exclude {
+		if e == t || strings.HasSuffix(e, "/") && strings.HasPrefix(t, e) {
+			return true
+		}
+	}
+	return false
+}
+
+// compressWriter 替换 cosweb.Response 内部的 ResponseWriter,
+// 状态码、是否已写入、Hijack 仍由 cosweb.Response 跟踪。
+// 数据不足 MinLength 时先缓存,确定是否压缩后再写出响应头
+type compressWriter struct {
+	http.ResponseWriter
+	compress *Compress
+	encoding string
+	status   int
+	buf      []byte
+	encoder  encoder
+	decided  bool
+	hijacked bool
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	if w.decided || w.status != 0 {
+		return
+	}
+	// 1xx 信息响应直接发送
+	if code >= 100 && code < 200 {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	w.status = code
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if w.hijacked {
+		return 0, nil
+	}
+	if !w.decided {
+		if len(w.buf)+len(b) < w.compress.MinLength {
+			w.buf = append(w.buf, b...)
+			return len(b), nil
+		}
+		w.buf = append(w.buf, b...)
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+	if w.encoder != nil {
+		return w.encoder.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// decide 确定是否压缩并写出响应头与已缓存的数据,compress 为 false 时不压缩
+func (w *compressWriter) decide(compress bool) (err error) {
+	w.decided = true
+	header := w.ResponseWriter.Header()
+	if header.Get(cosweb.HeaderContentType) == "" && len(w.buf) > 0 {
+		header.Set(cosweb.HeaderContentType, http.DetectContentType(w.buf))
+	}
+	status := w.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	if compress && w.compressible(status, header) {
+		header.Set(cosweb.HeaderContentEncoding, w.encoding)
+		header.Del(cosweb.HeaderContentLength)
+		header.Del("Accept-Ranges")
+		// 压缩后的字节与原响应不同,强 ETag 追加编码后缀,If-Match、If-None-Match 比较时忽略后缀
+		if etag := header.Get(cosweb.HeaderETag); etag != "" {
+			header.Set(cosweb.HeaderETag, cosweb.ETagWithEncoding(etag, w.encoding))
+		}
+		w.encoder = w.compress.pools[w.encoding].Get().(encoder)
+		w.encoder.Reset(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(status)
+	if len(w.buf) > 0 {
+		if w.encoder != nil {
+			_, err = w.encoder.Write(w.buf)
+		} else {
+			_, err = w.ResponseWriter.Write(w.buf)
+		}
+	}
+	w.buf = nil
+	return
+}
+
+func (w *compressWriter) compressible(status int, header http.Header) bool {
+	if status < 200 || status == http.StatusNoContent || status == http.StatusPartialContent || status == http.StatusNotModified {
+		return false
+	}
+	if header.Get(cosweb.HeaderContentEncoding) != "" || header.Get("Content-Range") != "" {
+		return false
+	}
+	return !w.compress.excluded(header.Get(cosweb.HeaderContentType))
+}
+
+// Flush 流式响应立即压缩并发送已写入的数据
+func (w *compressWriter) Flush() {
+	if w.hijacked {
+		return
+	}
+	if !w.decided {
+		if len(w.buf) == 0 && w.status == 0 {
+			return
+		}
+		_ = w.decide(len(w.buf) > 0)
+	}
+	if w.encoder != nil {
+		_ = w.encoder.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response does not implement http.Hijacker")
+	}
+	conn, buf, err := hijacker.Hijack()
+	if err == nil {
+		w.hijacked = true
+	}
+	return conn, buf, err
+}
+
+// Unwrap 供 http.ResponseController 访问底层 ResponseWriter
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Close 写出缓存的数据并结束压缩流,编码器放回缓存池
+func (w *compressWriter) Close() {
+	if w.hijacked {
+		return
+	}
+	if !w.decided {
+		if len(w.buf) == 0 && w.status == 0 {
+			return
+		}
+		_ = w.decide(false)
+	}
+	if w.encoder != nil {
+		_ = w.encoder.Close()
+		w.encoder.Reset(io.Discard)
+		w.compress.pools[w.encoding].Put(w.encoder)
+		w.encoder = nil
+	}
+}
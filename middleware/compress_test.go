@@ -0,0 +1,167 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hwcer/cosweb"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zlib"
+	"github.com/klauspost/compress/zstd"
+)
+
+func decode(t *testing.T, encoding string, body []byte) string {
+	t.Helper()
+	var r io.Reader
+	var err error
+	switch encoding {
+	case EncodingGzip:
+		r, err = gzip.NewReader(bytes.NewReader(body))
+	case EncodingDeflate:
+		r, err = zlib.NewReader(bytes.NewReader(body))
+	case EncodingZstd:
+		var d *zstd.Decoder
+		d, err = zstd.NewReader(bytes.NewReader(body))
+		r = d
+	default:
+		return string(body)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestCompress(t *testing.T) {
+	large := strings.Repeat(`{"key":"value"},`, 256)
+	s := cosweb.New()
+	s.Use(NewCompress().Middleware)
+	s.GET("/large", func(c *cosweb.Context) any {
+		return []byte(large)
+	})
+	s.GET("/small", func(c *cosweb.Context) any {
+		return []byte("ok")
+	})
+	s.GET("/png", func(c *cosweb.Context) any {
+		return c.Bytes(cosweb.ContentType("image/png"), []byte(large))
+	})
+	s.GET("/created", func(c *cosweb.Context) any {
+		c.WriteHeader(http.StatusCreated)
+		return []byte(large)
+	})
+
+	cases := []struct {
+		path, accept, encoding string
+		code                   int
+		want                   string
+	}{
+		{"/large", "gzip", EncodingGzip, http.StatusOK, large},
+		{"/large", "gzip;q=0.5, deflate", EncodingDeflate, http.StatusOK, large},
+		{"/large", "gzip, zstd", EncodingZstd, http.StatusOK, large},
+		{"/large", "br", "", http.StatusOK, large},
+		{"/large", "*", EncodingZstd, http.StatusOK, large},
+		{"/large", "", "", http.StatusOK, large},
+		{"/small", "gzip", "", http.StatusOK, "ok"},
+		{"/png", "gzip", "", http.StatusOK, large},
+		{"/created", "gzip", EncodingGzip, http.StatusCreated, large},
+	}
+	for i := 0; i < 2; i++ { //第二轮使用缓存池中的编码器
+		for _, tt := range cases {
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.accept != "" {
+				r.Header.Set(cosweb.HeaderAcceptEncoding, tt.accept)
+			}
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, r)
+			if got := w.Header().Get(cosweb.HeaderContentEncoding); got != tt.encoding {
+				t.Errorf("%s %q: Content-Encoding %q, want %q", tt.path, tt.accept, got, tt.encoding)
+				continue
+			}
+			if w.Code != tt.code {
+				t.Errorf("%s %q: status %d, want %d", tt.path, tt.accept, w.Code, tt.code)
+			}
+			if got := decode(t, tt.encoding, w.Body.Bytes()); got != tt.want {
+				t.Errorf("%s %q: body mismatch", tt.path, tt.accept)
+			}
+			if got := w.Header().Get(cosweb.HeaderVary); got != cosweb.HeaderAcceptEncoding {
+				t.Errorf("%s %q: Vary %q", tt.path, tt.accept, got)
+			}
+		}
+	}
+}
+
+// TestCompressVary 之前的中间件已声明 Accept-Encoding 时不重复追加 Vary
+func TestCompressVary(t *testing.T) {
+	s := cosweb.New()
+	s.Use(func(c *cosweb.Context, next cosweb.Next) error {
+		c.Header().Set(cosweb.HeaderVary, "Origin, accept-encoding")
+		return next()
+	})
+	s.Use(NewCompress().Middleware)
+	s.GET("/large", func(c *cosweb.Context) any {
+		return []byte(strings.Repeat("x", 4096))
+	})
+	r := httptest.NewRequest(http.MethodGet, "/large", nil)
+	r.Header.Set(cosweb.HeaderAcceptEncoding, "gzip")
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	vary := w.Header().Values(cosweb.HeaderVary)
+	if len(vary) == 0 || vary[0] != "Origin, accept-encoding" {
+		t.Fatalf("Vary: got %q", vary)
+	}
+	for _, v := range vary[1:] {
+		if strings.EqualFold(v, cosweb.HeaderAcceptEncoding) {
+			t.Errorf("Vary: Accept-Encoding added twice %q", vary)
+		}
+	}
+}
+
+// TestCompressETag 压缩后的强 ETag 追加编码后缀,If-None-Match 与 IfMatch 仍能匹配
+func TestCompressETag(t *testing.T) {
+	large := []byte(strings.Repeat(`{"key":"value"},`, 256))
+	s := cosweb.New()
+	s.ETag = cosweb.ETagStrong
+	s.Use(NewCompress().Middleware)
+	s.Register("/config", func(c *cosweb.Context) any {
+		if c.Request.Method == http.MethodPut {
+			if err := c.IfMatch(large); err != nil {
+				return err
+			}
+			return []byte("ok")
+		}
+		return large
+	}, http.MethodGet, http.MethodPut)
+
+	do := func(method, header, value string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "/config", nil)
+		r.Header.Set(cosweb.HeaderAcceptEncoding, EncodingGzip)
+		if header != "" {
+			r.Header.Set(header, value)
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		return w
+	}
+	w := do(http.MethodGet, "", "")
+	etag := w.Header().Get(cosweb.HeaderETag)
+	if w.Header().Get(cosweb.HeaderContentEncoding) != EncodingGzip || !strings.HasPrefix(etag, `"`) || !strings.HasSuffix(etag, `-gzip"`) {
+		t.Fatalf("GET: Content-Encoding %q, ETag %q", w.Header().Get(cosweb.HeaderContentEncoding), etag)
+	}
+	if w = do(http.MethodGet, cosweb.HeaderIfNoneMatch, etag); w.Code != http.StatusNotModified {
+		t.Errorf("If-None-Match %s: %d", etag, w.Code)
+	}
+	if w = do(http.MethodPut, cosweb.HeaderIfMatch, etag); w.Code != http.StatusOK {
+		t.Errorf("If-Match %s: %d", etag, w.Code)
+	}
+	if w = do(http.MethodPut, cosweb.HeaderIfMatch, strings.TrimSuffix(etag, `-gzip"`)+`-br2"`); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("If-Match with unknown suffix: %d", w.Code)
+	}
+}
@@ -0,0 +1,346 @@
+package cosweb
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// MultipartConfig multipart/form-data 上传配置,零值使用默认值
+type MultipartConfig struct {
+	MaxMemory    int64    //单个文件在内存中缓存的上限,超过后写入临时文件,默认使用 c.MaxCacheSize()
+	MaxFileSize  int64    //单个文件大小上限,超过时返回 413,0 不限制
+	MaxSize      int64    //整个请求体大小上限,超过时返回 413,默认使用 MaxBodySize;路由、Handler、请求级的限制优先
+	AllowedTypes []string //允许上传的文件 MIME 类型,校验 part 声明的 Content-Type 与按内容识别出的具体类型,支持 image/* 通配,为空不限制
+	TempDir      string   //上传文件与大请求体的临时文件目录,默认 os.TempDir()
+}
+
+var (
+	ErrMultipartInvalid   = NewHTTPError(http.StatusBadRequest, "invalid multipart form")
+	ErrFileTooLarge       = NewHTTPError(http.StatusRequestEntityTooLarge, "upload file too large")
+	ErrFileTypeNotAllowed = NewHTTPError(http.StatusUnsupportedMediaType, "upload file type not allowed")
+)
+
+// FormFile multipart 上传的文件,不超过 MaxMemory 时保存在内存,否则保存在临时文件,
+// 临时文件在 Context 释放时删除,需要保留时使用 SaveUploadedFile
+type FormFile struct {
+	Name     string //表单字段名
+	Filename string
+	Header   textproto.MIMEHeader
+	Size     int64
+	detected string
+	content  []byte
+	tmpfile  string
+}
+
+// ContentType part 声明的 Content-Type
+func (f *FormFile) ContentType() string {
+	return f.Header.Get(HeaderContentType)
+}
+
+// DetectedType 按文件开头的内容识别的 MIME 类型,参见 http.DetectContentType
+func (f *FormFile) DetectedType() string {
+	return f.detected
+}
+
+// Open 打开文件内容
+func (f *FormFile) Open() (multipart.File, error) {
+	if f.tmpfile != "" {
+		return os.Open(f.tmpfile)
+	}
+	return sectionReadCloser{io.NewSectionReader(bytes.NewReader(f.content), 0, int64(len(f.content)))}, nil
+}
+
+type sectionReadCloser struct {
+	*io.SectionReader
+}
+
+func (sectionReadCloser) Close() error {
+	return nil
+}
+
+// MultipartForm 解析后的 multipart 表单
+type MultipartForm struct {
+	Value map[string][]string
+	File  map[string][]*FormFile
+}
+
+// removeAll 删除所有临时文件
+func (form *MultipartForm) removeAll() {
+	for _, files := range form.File {
+		for _, f := range files {
+			if f.tmpfile != "" {
+				_ = os.Remove(f.tmpfile)
+				f.tmpfile = ""
+			}
+		}
+	}
+}
+
+// MultipartForm 解析 multipart/form-data 请求体,结果在请求内缓存
+func (c *Context) MultipartForm() (*MultipartForm, error) {
+	if c.multipart != nil {
+		return c.multipart, c.multipartErr
+	}
+	return c.parseMultipart(nil)
+}
+
+// EachFile 流式读取 multipart 请求体,每个文件读取完成后回调 f,返回 error 时停止读取并返回该错误。
+// 大文件边读边写入临时文件,不会整体加载到内存;已解析过的请求直接回调已缓存的文件。
+func (c *Context) EachFile(f func(file *FormFile) error) error {
+	if c.multipart == nil {
+		_, err := c.parseMultipart(f)
+		return err
+	}
+	if c.multipartErr != nil {
+		return c.multipartErr
+	}
+	for _, files := range c.multipart.File {
+		for _, file := range files {
+			if err := f(file); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// FormFile 返回字段 name 的第一个上传文件,不存在时返回 http.ErrMissingFile
+func (c *Context) FormFile(name string) (*FormFile, error) {
+	form, err := c.MultipartForm()
+	if err != nil {
+		return nil, err
+	}
+	if files := form.File[name]; len(files) > 0 {
+		return files[0], nil
+	}
+	return nil, http.ErrMissingFile
+}
+
+// SaveUploadedFile 保存上传文件到 dst,自动创建目录
+func (c *Context) SaveUploadedFile(file *FormFile, dst string) error {
+	src, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	if err = os.MkdirAll(filepath.Dir(dst), 0750); err != nil {
+		return err
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, src); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// isMultipart 请求体是否为 multipart/form-data
+func (c *Context) isMultipart() bool {
+	t := c.Request.Header.Get(HeaderContentType)
+	return strings.HasPrefix(strings.ToLower(t), string(ContentTypeMultipartForm))
+}
+
+// parseMultipart 逐个读取 part,出错时已写入的临时文件同样在 release 时删除
+func (c *Context) parseMultipart(f func(file *FormFile) error) (form *MultipartForm, err error) {
+	form = &MultipartForm{Value: map[string][]string{}, File: map[string][]*FormFile{}}
+	c.multipart = form
+	defer func() {
+		c.multipartErr = err
+	}()
+	if err = c.decodeBody(); err != nil {
+		return form, err
+	}
+	_, params, err := mime.ParseMediaType(c.Request.Header.Get(HeaderContentType))
+	if err != nil || params["boundary"] == "" {
+		return form, ErrMultipartInvalid
+	}
+	conf := &c.Server.Multipart
+	maxSize := conf.MaxSize
+	if maxSize <= 0 {
+		maxSize = c.Server.MaxBodySize
+	}
+	maxSize = c.bodyLimit(maxSize)
+	body := &limitedReader{r: c.Request.Body, n: maxSize}
+	reader := multipart.NewReader(body, params["boundary"])
+	var part *multipart.Part
+	for {
+		if part, err = reader.NextPart(); err == io.EOF {
+			return form, nil
+		} else if err != nil {
+			return form, c.multipartError(body, err)
+		}
+		name := part.FormName()
+		if name == "" {
+			continue
+		}
+		if part.FileName() == "" {
+			var b []byte
+			if b, err = readPart(part, c.maxMultipartMemory()); err != nil {
+				return form, c.multipartError(body, err)
+			}
+			form.Value[name] = append(form.Value[name], string(b))
+			continue
+		}
+		file := &FormFile{Name: name, Filename: part.FileName(), Header: part.Header}
+		if !allowedType(conf.AllowedTypes, file.ContentType()) {
+			return form, ErrFileTypeNotAllowed
+		}
+		// 先登记再写入,读取失败时临时文件同样会被清理
+		form.File[name] = append(form.File[name], file)
+		if err = c.readFile(file, part); err != nil {
+			return form, c.multipartError(body, err)
+		}
+		if f != nil {
+			if err = f(file); err != nil {
+				return form, err
+			}
+		}
+	}
+}
+
+// readFile 读取文件内容,超过 MaxMemory 后转存临时文件;
+// 按开头的内容识别文件类型,客户端声明的 Content-Type 不可信,识别出具体类型时同样须在 AllowedTypes 中
+func (c *Context) readFile(file *FormFile, part *multipart.Part) error {
+	var r io.Reader = part
+	maxFileSize := c.Server.Multipart.MaxFileSize
+	if maxFileSize > 0 {
+		r = io.LimitReader(part, maxFileSize+1)
+	}
+	maxMemory := c.maxMultipartMemory()
+	var buf bytes.Buffer
+	// 至少读取 http.DetectContentType 使用的 512 字节
+	n, err := io.CopyN(&buf, r, max(maxMemory+1, 512))
+	if err != nil && err != io.EOF {
+		return err
+	}
+	file.detected = http.DetectContentType(buf.Bytes())
+	if !genericType(file.detected) && !allowedType(c.Server.Multipart.AllowedTypes, file.detected) {
+		return ErrFileTypeNotAllowed
+	}
+	if n <= maxMemory {
+		file.content, file.Size = buf.Bytes(), n
+		if maxFileSize > 0 && n > maxFileSize {
+			return ErrFileTooLarge
+		}
+		return nil
+	}
+	tmp, err := os.CreateTemp(c.Server.Multipart.TempDir, "cosweb-upload-*")
+	if err != nil {
+		return err
+	}
+	file.tmpfile = tmp.Name()
+	size, err := io.Copy(tmp, io.MultiReader(&buf, r))
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	file.Size = size
+	if maxFileSize > 0 && size > maxFileSize {
+		return ErrFileTooLarge
+	}
+	return nil
+}
+
+func (c *Context) maxMultipartMemory() int64 {
+	if m := c.Server.Multipart.MaxMemory; m > 0 {
+		return m
+	}
+	return c.MaxCacheSize()
+}
+
+// readPart 读取普通字段,超过 limit 时返回 ErrFileTooLarge
+func readPart(part *multipart.Part, limit int64) ([]byte, error) {
+	b, err := io.ReadAll(io.LimitReader(part, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(b)) > limit {
+		return nil, ErrFileTooLarge
+	}
+	return b, nil
+}
+
+// genericTypes http.DetectContentType 无法确定具体格式时的结果:
+// JSON、CSV 识别为 text/plain,SVG 为 text/xml,docx/xlsx 等为 application/zip,这些结果不参与 AllowedTypes 校验
+var genericTypes = []string{"application/octet-stream", "text/plain", "text/xml", "application/zip"}
+
+// genericType 识别出的类型是否只是通用类型
+func genericType(contentType string) bool {
+	t, _, _ := strings.Cut(contentType, ";")
+	for _, g := range genericTypes {
+		if t == g {
+			return true
+		}
+	}
+	return false
+}
+
+// allowedType 校验 MIME 类型,支持 image/* 与 */* 通配
+func allowedType(allowed []string, contentType string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	t, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, a := range allowed {
+		a = strings.ToLower(a)
+		if a == t || a == "*/*" {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(a, "/*"); ok && strings.HasPrefix(t, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// multipartError 转换读取错误,超过 MaxSize 返回 413,格式错误返回 400
+func (c *Context) multipartError(body *limitedReader, err error) error {
+	var he *HTTPError
+	switch {
+	case body.exceeded:
+		return c.bodyTooLarge()
+	case errors.As(err, &he), errors.As(err, new(*os.PathError)):
+		return err
+	}
+	return ErrMultipartInvalid
+}
+
+// limitedReader 读取超过 n 字节时返回错误并标记 exceeded
+type limitedReader struct {
+	r        io.Reader
+	n        int64
+	exceeded bool
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.n < 0 {
+		l.exceeded = true
+		return 0, ErrRequestEntityTooLarge
+	}
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	if l.n < 0 {
+		l.exceeded = true
+		return n, ErrRequestEntityTooLarge
+	}
+	return n, err
+}
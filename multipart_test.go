@@ -0,0 +1,131 @@
+package cosweb
+
+import (
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func multipartRequest(t *testing.T, fields map[string]string, files map[string]string, fileType string) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for k, v := range fields {
+		_ = w.WriteField(k, v)
+	}
+	for name, content := range files {
+		h := textproto.MIMEHeader{}
+		h.Set("Content-Disposition", fmt.Sprintf(`form-data; name=%q; filename=%q`, name, name+".bin"))
+		h.Set(HeaderContentType, fileType)
+		part, err := w.CreatePart(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = part.Write([]byte(content))
+	}
+	_ = w.Close()
+	r := httptest.NewRequest(http.MethodPost, "/upload", &body)
+	r.Header.Set(HeaderContentType, w.FormDataContentType())
+	return r
+}
+
+// TestMultipartUpload 验证文件上传、大文件转存临时文件、大小与类型限制以及释放时清理临时文件。
+func TestMultipartUpload(t *testing.T) {
+	s := New()
+	s.Multipart.MaxMemory = 8
+	s.Multipart.MaxFileSize = 64
+	s.Multipart.AllowedTypes = []string{"image/*"}
+	dir := t.TempDir()
+	s.Multipart.TempDir = dir
+	var tmpfile string
+	s.Register("/upload", func(c *Context) any {
+		small, err := c.FormFile("small")
+		if err != nil {
+			return err
+		}
+		large, err := c.FormFile("large")
+		if err != nil {
+			return err
+		}
+		tmpfile = large.tmpfile
+		if small.tmpfile != "" || tmpfile == "" {
+			return fmt.Errorf("unexpected storage small=%q large=%q", small.tmpfile, tmpfile)
+		}
+		if err = c.SaveUploadedFile(large, filepath.Join(dir, "saved", "large.bin")); err != nil {
+			return err
+		}
+		return []byte(c.GetString("title") + ":" + strconv.FormatInt(small.Size, 10) + ":" + strconv.FormatInt(large.Size, 10))
+	}, http.MethodPost)
+
+	// 按内容识别类型,文件以 PNG 签名开头
+	png := "\x89PNG\r\n\x1a\n"
+	large := png + strings.Repeat("x", 24)
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, multipartRequest(t, map[string]string{"title": "t"}, map[string]string{"small": png, "large": large}, "image/png"))
+	if w.Code != http.StatusOK || w.Body.String() != "t:8:32" {
+		t.Fatalf("upload: %d %q", w.Code, w.Body.String())
+	}
+	if b, err := os.ReadFile(filepath.Join(dir, "saved", "large.bin")); err != nil || string(b) != large {
+		t.Errorf("saved file: %q %v", b, err)
+	}
+	if _, err := os.Stat(tmpfile); !os.IsNotExist(err) {
+		t.Errorf("temp file not removed after release: %v", err)
+	}
+
+	cases := []struct {
+		name     string
+		content  string
+		fileType string
+		want     int
+	}{
+		{"file too large", png + strings.Repeat("x", 57), "image/png", http.StatusRequestEntityTooLarge},
+		{"type not allowed", png, "text/html", http.StatusUnsupportedMediaType},
+		{"detected type not allowed", "<html><body>x</body></html>", "image/png", http.StatusUnsupportedMediaType},
+	}
+	for _, tt := range cases {
+		w = httptest.NewRecorder()
+		s.ServeHTTP(w, multipartRequest(t, nil, map[string]string{"small": png, "large": tt.content}, tt.fileType))
+		if w.Code != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.name, w.Code, tt.want)
+		}
+	}
+
+	// 通用识别结果只校验声明的类型
+	generic := New()
+	generic.Multipart.AllowedTypes = []string{"application/json", "image/svg+xml"}
+	generic.Register("/upload", func(c *Context) any {
+		f, err := c.FormFile("large")
+		if err != nil {
+			return err
+		}
+		return []byte(f.DetectedType())
+	}, http.MethodPost)
+	for _, tt := range []struct{ content, fileType string }{
+		{`{"a":1}`, "application/json"},
+		{`<svg xmlns="http://www.w3.org/2000/svg"></svg>`, "image/svg+xml"},
+	} {
+		w = httptest.NewRecorder()
+		generic.ServeHTTP(w, multipartRequest(t, nil, map[string]string{"large": tt.content}, tt.fileType))
+		if w.Code != http.StatusOK {
+			t.Errorf("%s detected as %q: got %d, want 200", tt.fileType, w.Body.String(), w.Code)
+		}
+	}
+
+	s.Multipart.MaxSize = 64
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, multipartRequest(t, nil, map[string]string{"small": png, "large": large}, "image/png"))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("total size: got %d, want 413", w.Code)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("temp files left: %v", entries)
+	}
+}
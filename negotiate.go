@@ -0,0 +1,248 @@
+package cosweb
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/hwcer/cosgo/binder"
+	"github.com/hwcer/logger"
+)
+
+// ErrNotAcceptable 路由声明了 Produces,但 Accept 中没有可接受的类型
+var ErrNotAcceptable = NewHTTPError(http.StatusNotAcceptable, http.StatusText(http.StatusNotAcceptable))
+
+// negotiableTypes 未声明 Produces 时,Accept 中的通配类型(application/*、*/*)按此顺序匹配,排在 Server.Binder 之后
+var negotiableTypes = []string{binder.MIMEJSON, binder.MIMEXML, binder.MIMEYAML, binder.MIMEPROTOBUF, binder.MIMEMSGPACK}
+
+// Produces 声明路由可以输出的 MIME 类型,按优先级排列,
+// Accept 中没有可接受的类型时返回 406,未声明时按已注册的 binder 协商
+//
+//	s.GET("/user/:id", user, cosweb.Produces(binder.MIMEJSON, binder.MIMEXML))
+func Produces(types ...string) RouteOption {
+	return func(r *route) {
+		for _, t := range types {
+			t = binder.ContentTypeFormat(t)
+			if binder.Get(t) == nil {
+				logger.Alert("route %s produces unknown mime type:%s", r.pattern, t)
+				continue
+			}
+			r.produces = append(r.produces, t)
+		}
+	}
+}
+
+// mediaRange Accept 中的一项
+type mediaRange struct {
+	typ string //type/subtype,已转小写
+	q   float64
+}
+
+// specificity 精确类型 2,type/* 为 1,*/* 为 0
+func (m *mediaRange) specificity() int {
+	switch {
+	case m.typ == "*/*":
+		return 0
+	case strings.HasSuffix(m.typ, "/*"):
+		return 1
+	}
+	return 2
+}
+
+func (m *mediaRange) match(t string) bool {
+	switch m.specificity() {
+	case 0:
+		return true
+	case 1:
+		return strings.HasPrefix(t, m.typ[:len(m.typ)-1])
+	}
+	return m.typ == t
+}
+
+// parseAccept 解析 Accept 追加到 ranges,按 q 值降序、出现顺序升序排列;q=0 的项保留用于排除。
+// 调用方传入栈数组,手动切割避免 strings.Split 的 []string 堆分配
+func parseAccept(ranges []mediaRange, header string) []mediaRange {
+	for header != "" {
+		var s string
+		if i := strings.IndexByte(header, ','); i >= 0 {
+			s, header = header[:i], header[i+1:]
+		} else {
+			s, header = header, ""
+		}
+		params := ""
+		if i := strings.IndexByte(s, ';'); i >= 0 {
+			s, params = s[:i], s[i+1:]
+		}
+		m := mediaRange{typ: lowerASCII(strings.TrimSpace(s)), q: 1}
+		if m.typ == "" {
+			continue
+		}
+		if m.typ == "*" {
+			m.typ = "*/*"
+		}
+		for params != "" {
+			var p string
+			if i := strings.IndexByte(params, ';'); i >= 0 {
+				p, params = params[:i], params[i+1:]
+			} else {
+				p, params = params, ""
+			}
+			k, v, _ := strings.Cut(p, "=")
+			if strings.EqualFold(strings.TrimSpace(k), "q") {
+				if q, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil && q >= 0 && q <= 1 {
+					m.q = q
+				}
+			}
+		}
+		// 插入排序保持同 q 值的出现顺序,Accept 通常只有几项,避免 sort.SliceStable 的分配
+		i := len(ranges)
+		ranges = append(ranges, m)
+		for ; i > 0 && ranges[i-1].q < m.q; i-- {
+			ranges[i] = ranges[i-1]
+		}
+		ranges[i] = m
+	}
+	return ranges
+}
+
+// lowerASCII 转小写,已是小写时直接返回,不分配
+func lowerASCII(s string) string {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; 'A' <= c && c <= 'Z' {
+			return strings.ToLower(s)
+		}
+	}
+	return s
+}
+
+// quality 按最具体的匹配项计算 t 的 q 值,没有匹配项时返回 0
+func quality(ranges []mediaRange, t string) float64 {
+	best, q := -1, 0.0
+	for i := range ranges {
+		if s := ranges[i].specificity(); s > best && ranges[i].match(t) {
+			best, q = s, ranges[i].q
+		}
+	}
+	return q
+}
+
+// negotiate 按 RFC 9110 协商响应类型:按 q 值从高到低、同 q 值按出现顺序选择第一个可输出的类型,
+// Accept 中没有可用类型时依次使用 Content-Type、Produces 首项或 Server.Binder;
+// 路由声明了 Produces 且 Accept 全部不可接受时 ok 为 false
+func (c *Context) negotiate() (b binder.Binder, ok bool) {
+	var produces []string
+	if c.entry != nil {
+		produces = c.entry.produces
+	}
+	if len(produces) != 1 {
+		AddVary(c.Header(), HeaderAccept)
+	}
+	candidates := produces
+	if len(candidates) == 0 {
+		candidates = c.Server.defaultProduces()
+	}
+	var buf [8]mediaRange
+	var ranges []mediaRange
+	// 未携带 Accept 或只有 */* 时无需解析
+	switch header := c.Request.Header.Get(HeaderAccept); header {
+	case "":
+	case "*/*":
+		buf[0] = mediaRange{typ: "*/*", q: 1}
+		ranges = buf[:1]
+	default:
+		ranges = parseAccept(buf[:0], header)
+	}
+	explicit := false
+	for i := range ranges {
+		m := &ranges[i]
+		if c.Server.AcceptIgnore[m.typ] {
+			continue
+		}
+		explicit = true
+		if m.q == 0 {
+			continue
+		}
+		if m.specificity() == 2 {
+			if b = c.produce(produces, m.typ); b != nil {
+				return b, true
+			}
+			continue
+		}
+		for _, t := range candidates {
+			if m.match(t) && quality(ranges, t) > 0 {
+				// 候选列表不随 binder 注册更新,未注册的类型在此跳过
+				if b = binder.Get(t); b != nil {
+					return b, true
+				}
+			}
+		}
+	}
+	if t := binder.ContentTypeFormat(c.Request.Header.Get(HeaderContentType)); t != "" && !c.Server.AcceptIgnore[t] {
+		if b = c.produce(produces, t); b != nil && (len(produces) == 0 || !explicit) {
+			return b, true
+		}
+	}
+	if len(produces) == 0 {
+		return c.Server.Binder, true
+	}
+	if explicit {
+		return nil, false
+	}
+	return binder.Get(produces[0]), true
+}
+
+// produce 返回可输出 t 的 binder,声明了 Produces 时 t 必须在其中
+func (c *Context) produce(produces []string, t string) binder.Binder {
+	if len(produces) > 0 {
+		found := false
+		for _, p := range produces {
+			if p == t {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil
+		}
+	}
+	return binder.Get(t)
+}
+
+// defaultProduces 未声明 Produces 时通配类型的候选列表,按 Server.Binder 缓存,Binder 修改后重新计算;
+// 列表包含全部 negotiableTypes,是否已注册 binder 在使用时检查,之后注册的 binder 同样参与协商
+func (srv *Server) defaultProduces() []string {
+	name := ""
+	if srv.Binder != nil {
+		name = srv.Binder.String()
+	}
+	if p := srv.produces.Load(); p != nil && p.binder == name {
+		return p.types
+	}
+	r := make([]string, 0, len(negotiableTypes)+1)
+	if name != "" {
+		r = append(r, name)
+	}
+	for _, t := range negotiableTypes {
+		if t != name {
+			r = append(r, t)
+		}
+	}
+	srv.produces.Store(&produceCache{binder: name, types: r})
+	return r
+}
+
+// produceCache Server.defaultProduces 的缓存
+type produceCache struct {
+	binder string
+	types  []string
+}
+
+// acceptable 声明了 Produces 的路由在执行 handler 前检查 Accept,没有可接受的类型时返回 406
+func acceptable(c *Context, next Next) error {
+	b, ok := c.negotiate()
+	if !ok {
+		return ErrNotAcceptable
+	}
+	c.accept = b
+	return next()
+}
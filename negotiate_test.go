@@ -0,0 +1,79 @@
+package cosweb
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hwcer/cosgo/binder"
+)
+
+// TestContentNegotiation 验证 q 值与通配类型协商、Produces 的 406 与 Vary: Accept。
+func TestContentNegotiation(t *testing.T) {
+	s := New()
+	type item struct {
+		A string `json:"a" xml:"a"`
+	}
+	reply := func(c *Context) any { return &item{A: "b"} }
+	s.GET("/any", reply)
+	s.GET("/typed", reply, Produces(binder.MIMEXML, binder.MIMEJSON))
+	s.GET("/json", reply, Produces(binder.MIMEJSON))
+
+	cases := []struct {
+		path   string
+		accept string
+		code   int
+		ctype  string
+		vary   bool
+	}{
+		{"/any", "", 200, binder.MIMEJSON, true},
+		{"/any", "application/json;q=0.5, application/xml", 200, binder.MIMEXML, true},
+		{"/any", "text/plain, application/*;q=0.8", 200, binder.MIMEJSON, true},
+		{"/any", "application/*, application/json;q=0", 200, binder.MIMEXML, true},
+		{"/any", "APPLICATION/XML;q=0.5, application/json;q=0.5", 200, binder.MIMEXML, true},
+		{"/typed", "*/*", 200, binder.MIMEXML, true},
+		{"/typed", "application/json, application/xml;q=0.9", 200, binder.MIMEJSON, true},
+		{"/typed", "image/png", http.StatusNotAcceptable, "", true},
+		{"/json", "application/xml;q=0.1, application/json;q=0.2", 200, binder.MIMEJSON, false},
+		{"/json", "application/xml", http.StatusNotAcceptable, "", false},
+	}
+	for _, tt := range cases {
+		r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		if tt.accept != "" {
+			r.Header.Set(HeaderAccept, tt.accept)
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		if w.Code != tt.code {
+			t.Errorf("%s %q: got %d, want %d", tt.path, tt.accept, w.Code, tt.code)
+			continue
+		}
+		if ct := w.Header().Get(HeaderContentType); tt.ctype != "" && !strings.HasPrefix(ct, tt.ctype) {
+			t.Errorf("%s %q: Content-Type %q, want %q", tt.path, tt.accept, ct, tt.ctype)
+		}
+		if vary := w.Header().Get(HeaderVary) == HeaderAccept; vary != tt.vary {
+			t.Errorf("%s %q: Vary %q", tt.path, tt.accept, w.Header().Get(HeaderVary))
+		}
+	}
+}
+
+// TestNegotiateAllocs 协商响应类型不应产生堆分配(首次调用写入 Vary 除外)。
+func TestNegotiateAllocs(t *testing.T) {
+	s := New()
+	for _, accept := range []string{"", "*/*", "application/json", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if accept != "" {
+			r.Header.Set(HeaderAccept, accept)
+		}
+		c := s.Acquire(httptest.NewRecorder(), r)
+		c.negotiate()
+		n := testing.AllocsPerRun(100, func() {
+			c.negotiate()
+		})
+		s.Release(c)
+		if n != 0 {
+			t.Errorf("Accept %q: %v allocs per negotiate", accept, n)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package cosweb
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/hwcer/cosgo/registry"
+)
+
+// PathAction 非规范路径的处理方式
+type PathAction int8
+
+const (
+	PathActionNone     PathAction = iota //不处理,由 registry 兼容匹配(默认)
+	PathActionRewrite                    //改写 Request.URL.Path 为规范路径后再路由,handler/Proxy 看到的是规范路径
+	PathActionRedirect                   //规范路径能匹配路由时重定向,GET/HEAD 使用 301,其他方法使用 308 保留请求方法与 body
+)
+
+// PathPolicy 路径规范化策略,零值保持 registry 的默认行为:
+// 末尾斜杠与重复斜杠在匹配时被忽略,静态段不区分大小写,按解码后的 Path 匹配。
+type PathPolicy struct {
+	TrailingSlash    PathAction //末尾斜杠 /user/ → /user
+	DuplicateSlashes PathAction //重复斜杠 //user → /user
+	RawPath          bool       //按 RawPath 匹配,参数中的 %2F 不作为路径分隔符,匹配后再解码参数
+	CaseSensitive    bool       //静态段区分大小写,仅对通过 Server.Register 注册的路由生效
+}
+
+// apply 对 path 执行处理方式为 action 的规范化
+func (p *PathPolicy) apply(path string, action PathAction) string {
+	if p.DuplicateSlashes == action && strings.Contains(path, "//") {
+		var b strings.Builder
+		b.Grow(len(path))
+		for i := 0; i < len(path); i++ {
+			if path[i] == '/' && i > 0 && path[i-1] == '/' {
+				continue
+			}
+			b.WriteByte(path[i])
+		}
+		path = b.String()
+	}
+	if p.TrailingSlash == action && len(path) > 1 && path[len(path)-1] == '/' {
+		if path = strings.TrimRight(path, "/"); path == "" {
+			path = "/"
+		}
+	}
+	return path
+}
+
+// normalize 按 PathPolicy 计算用于路由的路径,需要重定向时 redirect 为目标 URL
+func (srv *Server) normalize(c *Context) (path, redirect string) {
+	p := &srv.PathPolicy
+	u := c.Request.URL
+	raw := p.RawPath && u.RawPath != ""
+	if raw {
+		path = u.RawPath
+	} else {
+		path = u.Path
+	}
+	if p.TrailingSlash == PathActionNone && p.DuplicateSlashes == PathActionNone {
+		return
+	}
+	if s := p.apply(path, PathActionRewrite); s != path {
+		path = s
+		if raw {
+			u.RawPath = s
+			u.Path, _ = url.PathUnescape(s)
+		} else {
+			u.Path = s
+		}
+	}
+	if s := p.apply(path, PathActionRedirect); s != path {
+		path = s
+		// 以 // 或 /\ 开头的 Location 会被浏览器当作其他站点,重定向目标只保留一个前导斜杠
+		s = "/" + strings.TrimLeft(s, `/\`)
+		if raw {
+			redirect = s
+		} else {
+			redirect = (&url.URL{Path: s}).EscapedPath()
+		}
+		if u.RawQuery != "" {
+			redirect += "?" + u.RawQuery
+		}
+	}
+	return
+}
+
+// redirectPath 重定向到规范路径的链尾
+func redirectPath(target string) MiddlewareFunc {
+	return func(c *Context, _ Next) error {
+		code := http.StatusPermanentRedirect
+		if m := c.Request.Method; m == http.MethodGet || m == http.MethodHead {
+			code = http.StatusMovedPermanently
+		}
+		c.Header().Set(HeaderLocation, target)
+		c.WriteHeader(code)
+		return nil
+	}
+}
+
+// unescapeParams RawPath 匹配时解码路径参数
+func unescapeParams(params registry.Params) {
+	for i := range params {
+		if v, err := url.PathUnescape(params[i].Value); err == nil {
+			params[i].Value = v
+		}
+	}
+}
+
+// matchCase 校验 path 的静态段与注册路由 pattern 大小写一致,遇到通配段时结束比较
+func matchCase(pattern, path string) bool {
+	var ps, ss string
+	for {
+		ps, pattern = nextSegment(pattern)
+		ss, path = nextSegment(path)
+		if ps == "" || strings.HasPrefix(ps, registry.PathMatchVague) {
+			return true
+		}
+		if !strings.HasPrefix(ps, registry.PathMatchParam) && ps != ss {
+			return false
+		}
+	}
+}
+
+// nextSegment 跳过前导斜杠,返回第一段与剩余部分
+func nextSegment(s string) (string, string) {
+	s = strings.TrimLeft(s, "/")
+	if i := strings.IndexByte(s, '/'); i >= 0 {
+		return s[:i], s[i:]
+	}
+	return s, ""
+}
@@ -0,0 +1,160 @@
+package cosweb
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestPathPolicy 验证末尾斜杠/重复斜杠的重定向与改写、RawPath 参数解码和大小写敏感匹配。
+func TestPathPolicy(t *testing.T) {
+	echo := func(c *Context) any {
+		_ = c.String(c.Request.URL.Path + "|" + c.GetString("name", RequestDataTypeParam))
+		return nil
+	}
+	cases := []struct {
+		policy   PathPolicy
+		method   string
+		target   string
+		code     int
+		location string
+		body     string
+	}{
+		{PathPolicy{}, http.MethodGet, "/user/", http.StatusOK, "", "/user/|"},
+		{PathPolicy{TrailingSlash: PathActionRedirect}, http.MethodGet, "/user/?a=1", http.StatusMovedPermanently, "/user?a=1", ""},
+		{PathPolicy{TrailingSlash: PathActionRedirect}, http.MethodPost, "/user/", http.StatusPermanentRedirect, "/user", ""},
+		{PathPolicy{TrailingSlash: PathActionRedirect}, http.MethodGet, "/nothing/", http.StatusNotFound, "", ""},
+		{PathPolicy{DuplicateSlashes: PathActionRewrite}, http.MethodGet, "/files//a", http.StatusOK, "", "/files/a|a"},
+		{PathPolicy{RawPath: true}, http.MethodGet, "/files/a%2Fb", http.StatusOK, "", "/files/a/b|a/b"},
+		{PathPolicy{}, http.MethodGet, "/USER", http.StatusOK, "", "/USER|"},
+		{PathPolicy{CaseSensitive: true}, http.MethodGet, "/USER", http.StatusNotFound, "", ""},
+		{PathPolicy{CaseSensitive: true}, http.MethodGet, "/files/ABC", http.StatusOK, "", "/files/ABC|ABC"},
+	}
+	for _, tt := range cases {
+		s := New()
+		s.PathPolicy = tt.policy
+		s.Register("/user", echo, http.MethodGet, http.MethodPost)
+		s.GET("/files/:name", echo)
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest(tt.method, tt.target, nil))
+		if w.Code != tt.code {
+			t.Errorf("%+v %s %s: got status %d, want %d", tt.policy, tt.method, tt.target, w.Code, tt.code)
+			continue
+		}
+		if tt.location != "" && w.Header().Get(HeaderLocation) != tt.location {
+			t.Errorf("%+v %s: got Location %q, want %q", tt.policy, tt.target, w.Header().Get(HeaderLocation), tt.location)
+		}
+		if tt.body != "" && w.Body.String() != tt.body {
+			t.Errorf("%+v %s: got body %q, want %q", tt.policy, tt.target, w.Body.String(), tt.body)
+		}
+	}
+
+	// 通配路由下重定向目标不能以 // 或 /\ 开头,否则成为跳转到其他站点的开放重定向
+	s := New()
+	s.PathPolicy = PathPolicy{TrailingSlash: PathActionRedirect}
+	s.GET("/*", echo)
+	for _, target := range []string{"//evil.com/", "/\\evil.com/", "///evil.com//"} {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		if w.Code != http.StatusMovedPermanently {
+			t.Errorf("%s: got status %d, want %d", target, w.Code, http.StatusMovedPermanently)
+			continue
+		}
+		if loc := w.Header().Get(HeaderLocation); strings.HasPrefix(loc, "//") || strings.HasPrefix(loc, "/\\") {
+			t.Errorf("%s: open redirect to %q", target, loc)
+		}
+	}
+}
+
+// TestParamConstraints 验证参数约束:不满足时回落到其他路由或 404,满足时参数已转换。
+func TestParamConstraints(t *testing.T) {
+	s := New()
+	s.GET("/user/:id<int>", func(c *Context) any {
+		v, _ := c.Get("id", RequestDataTypeParam).(int64)
+		_ = c.String("id:" + strconv.FormatInt(v+1, 10))
+		return nil
+	})
+	s.GET("/user/*", func(c *Context) any {
+		_ = c.String("wild:" + c.GetString("*"))
+		return nil
+	})
+	s.GET("/file/:name<re:[a-z]+\\.png>", func(c *Context) any {
+		_ = c.String("file:" + c.GetString("name"))
+		return nil
+	})
+	s.GET("/u/:uuid<uuid>", func(c *Context) any {
+		_ = c.String("uuid")
+		return nil
+	})
+
+	cases := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/user/41", http.StatusOK, "id:42"},
+		{"/user/abc", http.StatusOK, "wild:abc"},
+		{"/file/a.png", http.StatusOK, "file:a.png"},
+		{"/file/A.png", http.StatusNotFound, ""},
+		{"/u/123e4567-e89b-12d3-a456-426614174000", http.StatusOK, "uuid"},
+		{"/u/123", http.StatusNotFound, ""},
+	}
+	for _, tt := range cases {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if w.Code != tt.code || (tt.body != "" && w.Body.String() != tt.body) {
+			t.Errorf("GET %s: got %d %q, want %d %q", tt.path, w.Code, w.Body.String(), tt.code, tt.body)
+		}
+	}
+
+	// 其他方法的 405/Allow 同样校验约束,不满足约束的路径为 404
+	s = New()
+	s.GET("/user/:id<int>", func(c *Context) any { return []byte("ok") })
+	methods := []struct {
+		method, path string
+		code         int
+		allow        string
+	}{
+		{http.MethodGet, "/user/abc", http.StatusNotFound, ""},
+		{http.MethodPost, "/user/abc", http.StatusNotFound, ""},
+		{http.MethodHead, "/user/abc", http.StatusNotFound, ""},
+		{http.MethodOptions, "/user/abc", http.StatusNotFound, ""},
+		{http.MethodPost, "/user/1", http.StatusMethodNotAllowed, "GET, HEAD, OPTIONS"},
+		{http.MethodHead, "/user/1", http.StatusOK, ""},
+	}
+	for _, tt := range methods {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+		if w.Code != tt.code || w.Header().Get(HeaderAllow) != tt.allow {
+			t.Errorf("%s %s: got %d Allow %q, want %d %q", tt.method, tt.path, w.Code, w.Header().Get(HeaderAllow), tt.code, tt.allow)
+		}
+	}
+
+	// 同一层的参数路由无法按约束区分,只有参数名或约束不同的第二条路由在注册时被拒绝
+	s = New()
+	s.GET("/user/:id<int>", func(c *Context) any { return []byte("id") })
+	s.GET("/user/:name", func(c *Context) any { return []byte("name") })
+	s.POST("/user/:name", func(c *Context) any { return []byte("post") })
+	for _, tt := range []struct {
+		method, path string
+		code         int
+		body         string
+	}{
+		{http.MethodGet, "/user/1", http.StatusOK, "id"},
+		{http.MethodGet, "/user/bob", http.StatusNotFound, ""},
+		{http.MethodPost, "/user/bob", http.StatusOK, "post"},
+	} {
+		if w := serveTest(s, tt.method, tt.path); w.Code != tt.code || (tt.body != "" && w.Body.String() != tt.body) {
+			t.Errorf("%s %s: got %d %q, want %d %q", tt.method, tt.path, w.Code, w.Body.String(), tt.code, tt.body)
+		}
+	}
+	if paramShape("/user/:id/*path") != paramShape("/user/:name/*") {
+		t.Error("paramShape should ignore param names")
+	}
+
+	if _, _, err := parseConstraints("/x/:id<nope>"); err == nil {
+		t.Errorf("unknown constraint should fail")
+	}
+}
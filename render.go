@@ -1,8 +1,10 @@
 package cosweb
 
 import (
-	"github.com/hwcer/cosweb/render"
+	"html/template"
 	"io"
+
+	"github.com/hwcer/cosweb/render"
 )
 
 // Render is the interface that wraps the Render function.
@@ -13,3 +15,20 @@ type Render interface {
 func NewRender(options *render.Options) *render.Render {
 	return render.New(options)
 }
+
+// NewRender 创建模板引擎并设为 srv.Render。
+// options.Funcs 未定义 url 时注入 srv.URL,模板中可使用 {{url "user" "id" .ID}} 生成路径。
+func (srv *Server) NewRender(options *render.Options) *render.Render {
+	if options == nil {
+		options = &render.Options{}
+	}
+	if options.Funcs == nil {
+		options.Funcs = template.FuncMap{}
+	}
+	if _, ok := options.Funcs["url"]; !ok {
+		options.Funcs["url"] = srv.URL
+	}
+	r := render.New(options)
+	srv.Render = r
+	return r
+}
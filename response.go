@@ -19,6 +19,7 @@ type Response struct {
 	status   int
 	written  bool //已写入响应体
 	hijacked bool
+	discard  bool //丢弃响应体(HEAD 回退到 GET 路由时)
 }
 
 func (res *Response) Hijack() (net.Conn, *bufio.ReadWriter, error) {
@@ -34,6 +35,47 @@ func (res *Response) Hijack() (net.Conn, *bufio.ReadWriter, error) {
 	return conn, buf, err
 }
 
+// Flush 实现 http.Flusher,参见 FlushError
+func (res *Response) Flush() {
+	_ = res.FlushError()
+}
+
+// FlushError 立即发送已写入的数据,未设置状态码时以 200 提交响应头,之后 CanWrite 返回 false;
+// 底层 ResponseWriter 不支持时返回 http.ErrNotSupported
+func (res *Response) FlushError() error {
+	if res.hijacked {
+		return nil
+	}
+	// 不支持时不提交响应头,仍可返回错误响应
+	if !canFlush(res.ResponseWriter) {
+		return http.ErrNotSupported
+	}
+	if res.status == 0 {
+		res.WriteHeader(http.StatusOK)
+	}
+	res.written = true
+	return http.NewResponseController(res.ResponseWriter).Flush()
+}
+
+// canFlush 沿 Unwrap 链查找 http.Flusher,与 http.ResponseController 的查找方式一致
+func canFlush(w http.ResponseWriter) bool {
+	for {
+		switch t := w.(type) {
+		case http.Flusher, interface{ FlushError() error }:
+			return true
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = t.Unwrap()
+		default:
+			return false
+		}
+	}
+}
+
+// Unwrap 返回底层 ResponseWriter,供 http.NewResponseController 调用 SetWriteDeadline、EnableFullDuplex 等
+func (res *Response) Unwrap() http.ResponseWriter {
+	return res.ResponseWriter
+}
+
 // CanWrite 表示仍可产生响应。当上层(handler.write/HTTPErrorHandler)据此判断
 // 是否需要再生成响应体。一旦开始写 body 或已劫持,返回 false,避免重复写。
 func (res *Response) CanWrite() bool {
@@ -48,6 +90,9 @@ func (res *Response) Write(b []byte) (n int, err error) {
 		res.WriteHeader(http.StatusOK)
 	}
 	res.written = true
+	if res.discard {
+		return len(b), nil
+	}
 	return res.ResponseWriter.Write(b)
 }
 
@@ -99,6 +144,9 @@ func (c *Context) contentDisposition(file, name, dispositionType string) error {
 }
 
 func (c *Context) Bytes(contentType ContentType, b []byte) (err error) {
+	if c.notModified(b) {
+		return nil
+	}
 	c.writeContentType(contentType)
 	_, err = c.Response.Write(b)
 	return
@@ -142,6 +190,30 @@ func (c *Context) Stream(contentType ContentType, r io.Reader) (err error) {
 	return
 }
 
+// StreamFlush 与 Stream 相同,但每读到一块数据立即写出并 flush,适用于长轮询、日志输出等持续产生数据的 reader
+func (c *Context) StreamFlush(contentType ContentType, r io.Reader) (err error) {
+	c.writeContentType(contentType)
+	c.Header().Del(HeaderContentLength)
+	buf := make([]byte, 32<<10)
+	for {
+		n, rerr := r.Read(buf)
+		if n > 0 {
+			if _, err = c.Response.Write(buf[:n]); err != nil {
+				return
+			}
+			if err = c.Response.FlushError(); err != nil {
+				return
+			}
+		}
+		if rerr == io.EOF {
+			return nil
+		}
+		if rerr != nil {
+			return rerr
+		}
+	}
+}
+
 // Inline 最终走File
 func (c *Context) Inline(file, name string) error {
 	return c.contentDisposition(file, name, "inline")
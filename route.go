@@ -0,0 +1,533 @@
+package cosweb
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+
+	"github.com/hwcer/cosgo/registry"
+	"github.com/hwcer/cosgo/values"
+	"github.com/hwcer/logger"
+)
+
+// route 通过 Server.Register 注册的路由附加信息,以 registry.Node 为键保存在 Server.routes。
+// registry.Node 无法扩展字段,因此由 cosweb 维护这张旁路表;通过 Service 批量注册的 struct 不在表中。
+//
+// 并发模型:路由树与路由表只在开始处理请求前注册,之后只读;
+// 运行时的 Unregister/Replace 以及重新 Register 已注销的方法只原子替换 entries,不修改路由树与路由表。
+type route struct {
+	name        string                       //路由名称,用于 Server.URL 反向生成路径
+	pattern     string                       //注册时的完整路由(不含参数约束),如 /user/:id
+	method      []string                     //注册到路由树的 HTTP 方法
+	routeConfig                              //注册选项,写入各方法的 entries
+	entries     atomic.Pointer[[]routeEntry] //各 HTTP 方法当前生效的 handler,不在其中的方法视为已注销
+}
+
+// routeConfig 路由选项中随 handler 生效的部分,保存在各方法的 routeEntry 中,
+// 重新注册已注销的方法时与 handler 一起整体替换
+type routeConfig struct {
+	constraints  []paramConstraint //路由参数约束,如 /user/:id<int>
+	produces     []string          //可输出的 MIME 类型,参见 Produces
+	maxBodySize  int64             //路由级最大请求体大小,参见 WithMaxBodySize
+	maxCacheSize int64
+	etag         ETagMode         //路由级 ETag 生成方式,参见 WithETag
+	middleware   []MiddlewareFunc //路由级中间件(含分组中间件),在全局、服务中间件之后执行
+}
+
+// routeEntry 路由在某个 HTTP 方法下生效的 handler 与路由选项
+type routeEntry struct {
+	method  string
+	handler func(*Context) any
+	routeConfig
+}
+
+// entry 返回 method 当前生效的 routeEntry,已注销时返回 nil
+func (r *route) entry(method string) *routeEntry {
+	entries := *r.entries.Load()
+	for i := range entries {
+		if entries[i].method == method {
+			return &entries[i]
+		}
+	}
+	return nil
+}
+
+// update 复制当前 entries,对每个 entry 调用 f(返回 false 时删除)后原子替换,调用方需持有 Server.mu
+func (r *route) update(f func(e *routeEntry) bool) {
+	entries := *r.entries.Load()
+	next := make([]routeEntry, 0, len(entries))
+	for _, e := range entries {
+		if f(&e) {
+			next = append(next, e)
+		}
+	}
+	r.entries.Store(&next)
+}
+
+// RouteOption 路由选项,用于 Route/GET/POST
+type RouteOption func(*route)
+
+// WithMiddleware 路由级中间件,在全局、服务及分组中间件之后执行
+func WithMiddleware(middleware ...MiddlewareFunc) RouteOption {
+	return func(r *route) {
+		for _, m := range middleware {
+			if m != nil {
+				r.middleware = append(r.middleware, m)
+			}
+		}
+	}
+}
+
+// WithName 路由名称,配合 Server.URL / Context.URL 反向生成路径
+func WithName(name string) RouteOption {
+	return func(r *route) {
+		r.name = name
+	}
+}
+
+// WithMethods 路由匹配的 HTTP 方法,为空时匹配所有方法
+func WithMethods(method ...string) RouteOption {
+	return func(r *route) {
+		r.method = append(r.method, method...)
+	}
+}
+
+// register 注册路由,middleware 为分组中间件,排在路由中间件之前
+func (srv *Server) register(path string, handler func(*Context) any, options []RouteOption, middleware []MiddlewareFunc) {
+	path, constraints, err := parseConstraints(path)
+	if err != nil {
+		logger.Alert(err)
+		return
+	}
+	// 截断容量,路由中间件 append 时不会写入分组共享的底层数组
+	r := &route{pattern: registry.Join(path)}
+	r.constraints = constraints
+	r.middleware = middleware[:len(middleware):len(middleware)]
+	for _, opt := range options {
+		if opt != nil {
+			opt(r)
+		}
+	}
+	if len(r.method) == 0 {
+		r.method = AnyHttpMethod
+	}
+	r.method = upperMethods(r.method)
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	// 已注册的路由只允许重新启用已注销的方法,新的路由选项随 handler 一起生效
+	if old := srv.lookup(r.pattern); old != nil {
+		if err = old.enable(r.method, handler, r.routeConfig); err != nil {
+			logger.Alert(err)
+			return
+		}
+		srv.setName(old, r.name)
+		return
+	}
+	// 路由树与路由表在处理请求时不加锁读取,开始处理请求后不能再添加新路由
+	if srv.serving.Load() {
+		logger.Alert("route %s: new routes must be registered before the server starts serving requests", path)
+		return
+	}
+	if other := srv.conflict(r); other != nil {
+		logger.Alert("route %s conflicts with %s: routes that differ only in param names or constraints cannot share a method, "+
+			"the router has one param node per level; use a different static prefix or one route that checks the param", path, other.pattern)
+		return
+	}
+	service := srv.Service()
+	var nodes []*registry.Node
+	nodes, err = service.Parse(handler, path)
+	if err != nil {
+		logger.Alert(err)
+		return
+	}
+	srv.setName(r, r.name)
+	entries := make([]routeEntry, 0, len(r.method))
+	for _, m := range r.method {
+		entries = append(entries, routeEntry{method: m, handler: handler, routeConfig: r.routeConfig})
+	}
+	r.entries.Store(&entries)
+	for _, node := range nodes {
+		if err = srv.Registry.Router().Register(node, r.method); err != nil {
+			logger.Alert(fmt.Errorf("router register route=%s: %w", node.Name(), err))
+			continue
+		}
+		srv.routes[node] = r
+	}
+}
+
+// setName 登记路由名称,调用方需持有 Server.mu
+func (srv *Server) setName(r *route, name string) {
+	if name == "" {
+		return
+	}
+	if v, ok := srv.names[name]; ok && v != r {
+		logger.Alert("route name exist:%v route:%v", name, r.pattern)
+		return
+	}
+	srv.names[name] = r
+}
+
+// enable 重新启用已注销的方法,使用新的 handler 与路由选项,调用方需持有 Server.mu
+func (r *route) enable(method []string, handler func(*Context) any, config routeConfig) error {
+	for _, m := range method {
+		if !hasMethod(r.method, m) {
+			// registry 每个路径只有一个节点,节点的方法在首次注册时确定
+			return fmt.Errorf("route %s: path already registered with a different handler for %s, method %s cannot be added; register all methods of a path together",
+				r.pattern, strings.Join(r.method, ","), m)
+		}
+		if r.entry(m) != nil {
+			return fmt.Errorf("route exist:%s/%s", m, r.pattern)
+		}
+	}
+	entries := append([]routeEntry(nil), *r.entries.Load()...)
+	for _, m := range method {
+		entries = append(entries, routeEntry{method: m, handler: handler, routeConfig: config})
+	}
+	r.entries.Store(&entries)
+	return nil
+}
+
+// Unregister 注销路由,method 为空时注销全部方法,可在运行时与 ServeHTTP 并发调用。
+// 注销后的请求视为未匹配(404,其他方法仍生效时 405);之后可再次 Register 同一路由与方法恢复服务。
+func (srv *Server) Unregister(path string, method ...string) error {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	r, err := srv.lookupRoute(path)
+	if err != nil {
+		return err
+	}
+	method = upperMethods(method)
+	r.update(func(e *routeEntry) bool {
+		return len(method) > 0 && !hasMethod(method, e.method)
+	})
+	return nil
+}
+
+// Replace 替换路由的 handler,method 为空时替换全部生效的方法,可在运行时与 ServeHTTP 并发调用。
+// 已在处理中的请求继续使用旧 handler,路由中间件等选项保持不变;需要修改选项时先 Unregister 再重新注册。
+func (srv *Server) Replace(path string, handler func(*Context) any, method ...string) error {
+	if handler == nil {
+		return fmt.Errorf("route %s replace handler is nil", path)
+	}
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	r, err := srv.lookupRoute(path)
+	if err != nil {
+		return err
+	}
+	method = upperMethods(method)
+	r.update(func(e *routeEntry) bool {
+		if len(method) == 0 || hasMethod(method, e.method) {
+			e.handler = handler
+		}
+		return true
+	})
+	return nil
+}
+
+// lookupRoute 按注册时的路由(可含参数约束)查找,调用方需持有 Server.mu
+func (srv *Server) lookupRoute(path string) (*route, error) {
+	p, _, err := parseConstraints(path)
+	if err != nil {
+		return nil, err
+	}
+	if r := srv.lookup(registry.Join(p)); r != nil {
+		return r, nil
+	}
+	return nil, fmt.Errorf("route not found:%s", path)
+}
+
+// lookup 按规范化后的路由查找,调用方需持有 Server.mu
+func (srv *Server) lookup(pattern string) *route {
+	for _, r := range srv.routes {
+		if strings.EqualFold(r.pattern, pattern) {
+			return r
+		}
+	}
+	return nil
+}
+
+// conflict 返回与 r 只有参数名不同(约束已在 pattern 中去除)且有相同方法的已注册路由,调用方需持有 Server.mu。
+// registry 每层只有一个参数节点,这样的路由无法按约束区分,后注册的路由在同一方法下不会被匹配
+func (srv *Server) conflict(r *route) *route {
+	shape := paramShape(r.pattern)
+	for _, v := range srv.routes {
+		if !strings.EqualFold(paramShape(v.pattern), shape) {
+			continue
+		}
+		for _, m := range r.method {
+			if hasMethod(v.method, m) {
+				return v
+			}
+		}
+	}
+	return nil
+}
+
+// paramShape 去掉参数名与通配段名称后的路由,如 /user/:id/*path → /user/:/*
+func paramShape(pattern string) string {
+	parts := registry.Split(pattern)
+	for i, part := range parts {
+		switch {
+		case strings.HasPrefix(part, registry.PathMatchParam):
+			parts[i] = registry.PathMatchParam
+		case strings.HasPrefix(part, registry.PathMatchVague):
+			parts[i] = registry.PathMatchVague
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// routeOf 返回节点对应的路由附加信息,不存在时返回 nil
+func (srv *Server) routeOf(node *registry.Node) *route {
+	if node == nil {
+		return nil
+	}
+	return srv.routes[node]
+}
+
+func upperMethods(method []string) []string {
+	r := make([]string, len(method))
+	for i, m := range method {
+		r[i] = strings.ToUpper(m)
+	}
+	return r
+}
+
+func hasMethod(method []string, m string) bool {
+	for _, v := range method {
+		if v == m {
+			return true
+		}
+	}
+	return false
+}
+
+// URL 按路由名称生成路径。params 为 key,value 成对出现:
+// 与路由 :param 同名的填入路径,"*" 填入通配段,其余拼接为查询字符串。
+//
+//	s.GET("/user/:id", h, WithName("user"))
+//	s.URL("user", "id", 42, "tab", "info") // /user/42?tab=info
+func (srv *Server) URL(name string, params ...any) (string, error) {
+	srv.mu.Lock()
+	r := srv.names[name]
+	srv.mu.Unlock()
+	if r == nil {
+		return "", fmt.Errorf("route name not found:%s", name)
+	}
+	if len(params)%2 != 0 {
+		return "", fmt.Errorf("route %s params must be key-value pairs", name)
+	}
+	args := make(map[string]string, len(params)/2)
+	keys := make([]string, 0, len(params)/2)
+	for i := 0; i < len(params); i += 2 {
+		k := values.ParseString(params[i])
+		if _, ok := args[k]; !ok {
+			keys = append(keys, k)
+		}
+		args[k] = values.ParseString(params[i+1])
+	}
+	var b strings.Builder
+	for _, part := range registry.Split(r.pattern) {
+		if part == "" {
+			continue
+		}
+		b.WriteByte('/')
+		switch {
+		case strings.HasPrefix(part, registry.PathMatchParam):
+			k := strings.TrimPrefix(part, registry.PathMatchParam)
+			v, ok := args[k]
+			if !ok {
+				return "", fmt.Errorf("route %s missing param:%s", name, k)
+			}
+			b.WriteString(url.PathEscape(v))
+			delete(args, k)
+		case strings.HasPrefix(part, registry.PathMatchVague):
+			// 通配段可包含多级路径,逐段转义
+			v, ok := args[registry.PathMatchVague]
+			if !ok {
+				v, ok = args[strings.TrimPrefix(part, registry.PathMatchVague)]
+			}
+			delete(args, registry.PathMatchVague)
+			delete(args, strings.TrimPrefix(part, registry.PathMatchVague))
+			segments := strings.Split(strings.TrimPrefix(v, "/"), "/")
+			for i, seg := range segments {
+				segments[i] = url.PathEscape(seg)
+			}
+			b.WriteString(strings.Join(segments, "/"))
+		default:
+			b.WriteString(part)
+		}
+	}
+	if b.Len() == 0 {
+		b.WriteByte('/')
+	}
+	if len(args) > 0 {
+		query := url.Values{}
+		for _, k := range keys {
+			if v, ok := args[k]; ok {
+				query.Set(k, v)
+			}
+		}
+		b.WriteByte('?')
+		b.WriteString(query.Encode())
+	}
+	return b.String(), nil
+}
+
+// search 查找路由节点,结果写入 c.node/c.params/c.allow
+func (srv *Server) search(c *Context, path, sub string) {
+	var rejected bool
+	c.node, c.params, rejected = srv.match(c, c.Request.Method, path)
+	if c.node == nil && !rejected {
+		c.node, c.params, c.allow = srv.fallbackSearch(c, path)
+	}
+	if srv.PathPolicy.RawPath && c.Request.URL.RawPath != "" {
+		unescapeParams(c.params)
+	}
+	if sub != "" {
+		c.params = append(c.params, registry.Param{Key: HostParamSubdomain, Value: sub})
+	}
+}
+
+// match 按方法查找路由并校验是否生效、大小写与参数约束,未通过时按优先级尝试其他匹配的路由;
+// registry 命中但大小写或参数约束校验未通过时 rejected 为 true
+func (srv *Server) match(c *Context, method, path string) (node *registry.Node, params registry.Params, rejected bool) {
+	if node, params = srv.Registry.Search(method, path); node == nil {
+		return
+	}
+	var ok bool
+	if ok, rejected = srv.accept(c, method, node, params, path); ok {
+		return
+	}
+	for _, res := range srv.Registry.SearchAll(method, path) {
+		if res.Node == node {
+			continue
+		}
+		if ok, rej := srv.accept(c, method, res.Node, res.Params, path); ok {
+			return res.Node, res.Params, false
+		} else if rej {
+			rejected = true
+		}
+	}
+	return nil, nil, rejected
+}
+
+// accept 校验路由在 method 下是否生效以及大小写与参数约束,通过时写入 c.entry;
+// 方法已注销时 ok 与 rejected 均为 false
+func (srv *Server) accept(c *Context, method string, node *registry.Node, params registry.Params, path string) (ok, rejected bool) {
+	r := srv.routeOf(node)
+	if r == nil {
+		c.entry = nil
+		return true, false
+	}
+	e := r.entry(method)
+	if e == nil {
+		return false, false
+	}
+	if srv.PathPolicy.CaseSensitive && !matchCase(r.pattern, path) {
+		return false, true
+	}
+	if !e.constrain(c, params) {
+		return false, true
+	}
+	c.entry = e
+	return true, false
+}
+
+// fallbackSearch 请求方法未命中时的补充查找:
+//   - HEAD 回退到 GET 路由,响应体丢弃
+//   - 路径在其他方法下存在时返回允许的方法列表,由 doDispatch 响应 405 或自动 OPTIONS
+func (srv *Server) fallbackSearch(c *Context, path string) (node *registry.Node, params registry.Params, allow []string) {
+	if c.Request.Method == http.MethodHead {
+		if node, params, _ = srv.match(c, http.MethodGet, path); node != nil {
+			c.response.discard = true
+			return
+		}
+	}
+	return nil, nil, srv.allowed(c, path)
+}
+
+// exists path 在任一 HTTP 方法下是否有注册的路由,只查找路由树,不校验大小写与参数约束
+func (srv *Server) exists(path string) bool {
+	for _, m := range AnyHttpMethod {
+		if node, _ := srv.Registry.Search(m, path); node != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// allowed 返回 path 上生效的 HTTP 方法,与请求匹配相同,校验大小写与参数约束;路径不存在时返回 nil。
+// 先用 exists 确认路径存在再逐个方法完整匹配,未注册的路径(404)不做多余的查找
+func (srv *Server) allowed(c *Context, path string) (r []string) {
+	if !srv.exists(path) {
+		return nil
+	}
+	entry, values := c.entry, c.values
+	defer func() {
+		c.entry, c.values = entry, values
+	}()
+	var get, head, options bool
+	for _, m := range AnyHttpMethod {
+		if node, _, _ := srv.match(c, m, path); node != nil {
+			r = append(r, m)
+			switch m {
+			case http.MethodGet:
+				get = true
+			case http.MethodHead:
+				head = true
+			case http.MethodOptions:
+				options = true
+			}
+		}
+	}
+	if len(r) == 0 {
+		return nil
+	}
+	if get && !head {
+		r = append(r, http.MethodHead)
+	}
+	if srv.AutoOptions && !options {
+		r = append(r, http.MethodOptions)
+	}
+	return
+}
+
+// methodOptions 将 HTTP 方法列表转为 register 的 options
+func methodOptions(method []string) []RouteOption {
+	if len(method) == 0 {
+		return nil
+	}
+	return []RouteOption{WithMethods(method...)}
+}
+
+// anyOptions 将 Register 的 options 转为 RouteOption:string、[]string 为 HTTP 方法,其他类型记录错误后忽略
+func anyOptions(path string, options []any) []RouteOption {
+	r := make([]RouteOption, 0, len(options))
+	for _, opt := range options {
+		switch v := opt.(type) {
+		case string:
+			r = append(r, WithMethods(v))
+		case []string:
+			r = append(r, WithMethods(v...))
+		case RouteOption:
+			r = append(r, v)
+		case []RouteOption:
+			r = append(r, v...)
+		default:
+			logger.Alert("unknown route option type:%T route:%v", opt, path)
+		}
+	}
+	return r
+}
+
+// routeOptions 在 RouteOption 前加入固定的 HTTP 方法
+func routeOptions(method string, options []RouteOption) []RouteOption {
+	r := make([]RouteOption, 0, len(options)+1)
+	r = append(r, WithMethods(method))
+	return append(r, options...)
+}
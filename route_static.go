@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"github.com/hwcer/cosgo"
+	"github.com/hwcer/cosgo/registry"
 	"github.com/hwcer/logger"
 )
 
@@ -40,9 +41,13 @@ func (this *Static) Nocache(v bool) {
 	this.nocache = v
 }
 
+// Handle 通过通配路由注册时按通配段查找文件;作为 Server.Fallback 使用时没有通配段,按请求路径查找
 func (this *Static) Handle(c *Context) any {
-	name := c.GetString("*", RequestDataTypeParam)
-	if name == "" {
+	name, ok := c.params.Get(registry.PathMatchVague)
+	if !ok {
+		name = c.Request.URL.Path
+	}
+	if name == "" || name == "/" {
 		name = this.index
 	}
 	safe := filepath.Clean("/" + name)
@@ -0,0 +1,135 @@
+package cosweb
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func serveTest(s *Server, method, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+	return w
+}
+
+// TestUnregisterReplace 验证运行时注销、恢复与替换路由 handler。
+func TestUnregisterReplace(t *testing.T) {
+	s := New()
+	s.Register("/user/:id<int>", func(c *Context) any { return []byte("v1") }, http.MethodGet, http.MethodPost)
+
+	if w := serveTest(s, http.MethodGet, "/user/1"); w.Body.String() != "v1" {
+		t.Fatalf("GET before replace: %d %q", w.Code, w.Body.String())
+	}
+	if err := s.Replace("/user/:id", func(c *Context) any { return []byte("v2") }); err != nil {
+		t.Fatal(err)
+	}
+	if w := serveTest(s, http.MethodPost, "/user/1"); w.Body.String() != "v2" {
+		t.Fatalf("POST after replace: %d %q", w.Code, w.Body.String())
+	}
+
+	if err := s.Unregister("/user/:id<int>", http.MethodPost); err != nil {
+		t.Fatal(err)
+	}
+	w := serveTest(s, http.MethodPost, "/user/1")
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("POST after unregister: got %d, want 405", w.Code)
+	}
+	if allow := w.Header().Get(HeaderAllow); allow != "GET, HEAD, OPTIONS" {
+		t.Errorf("Allow: got %q", allow)
+	}
+	if err := s.Unregister("/user/:id"); err != nil {
+		t.Fatal(err)
+	}
+	if w = serveTest(s, http.MethodGet, "/user/1"); w.Code != http.StatusNotFound {
+		t.Fatalf("GET after unregister: got %d, want 404", w.Code)
+	}
+
+	// 重新注册时使用新的路由选项:去掉 <int> 约束,并附加路由中间件
+	mw := func(c *Context, next Next) error {
+		c.Header().Set("X-Route", "v3")
+		return next()
+	}
+	s.Route("/user/:id", func(c *Context) any { return []byte("v3") }, WithMethods(http.MethodGet), WithMiddleware(mw))
+	if w = serveTest(s, http.MethodGet, "/user/1"); w.Body.String() != "v3" || w.Header().Get("X-Route") != "v3" {
+		t.Fatalf("GET after re-register: %d %q", w.Code, w.Body.String())
+	}
+	if w = serveTest(s, http.MethodGet, "/user/x"); w.Body.String() != "v3" {
+		t.Errorf("constraint after re-register: got %d %q, want v3", w.Code, w.Body.String())
+	}
+	if err := s.Unregister("/missing"); err == nil {
+		t.Error("Unregister missing route: want error")
+	}
+
+	// 开始处理请求后不能添加新路由,也不能为已有路径添加方法
+	s.GET("/late", func(c *Context) any { return []byte("late") })
+	if w = serveTest(s, http.MethodGet, "/late"); w.Code != http.StatusNotFound {
+		t.Errorf("route registered after serving: got %d, want 404", w.Code)
+	}
+	s.Register("/user/:id", func(c *Context) any { return []byte("put") }, http.MethodPut)
+	if w = serveTest(s, http.MethodPut, "/user/1"); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("PUT added to existing path: got %d, want 405", w.Code)
+	}
+}
+
+// TestRouteHotSwapConcurrent 并发请求期间反复注销、注册与替换路由,配合 go test -race 检查数据竞争。
+func TestRouteHotSwapConcurrent(t *testing.T) {
+	s := New()
+	mw := func(c *Context, next Next) error { return next() }
+	s.Route("/hot", func(c *Context) any { return []byte("a") }, WithMethods(http.MethodGet), WithMiddleware(mw))
+	s.GET("/stable", func(c *Context) any { return []byte("ok") })
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if w := serveTest(s, http.MethodGet, "/hot"); w.Code != http.StatusOK && w.Code != http.StatusNotFound {
+					t.Errorf("GET /hot: unexpected status %d", w.Code)
+					return
+				} else if w.Code == http.StatusOK && w.Body.String() != "a" && w.Body.String() != "b" {
+					t.Errorf("GET /hot: unexpected body %q", w.Body.String())
+					return
+				}
+				if w := serveTest(s, http.MethodGet, "/stable"); w.Body.String() != "ok" {
+					t.Errorf("GET /stable: %d %q", w.Code, w.Body.String())
+					return
+				}
+			}
+		}()
+	}
+	for i := 0; i < 200; i++ {
+		if err := s.Unregister("/hot"); err != nil {
+			t.Fatal(err)
+		}
+		s.Route("/hot", func(c *Context) any { return []byte("a") }, WithMethods(http.MethodGet), WithMiddleware(mw))
+		if err := s.Replace("/hot", func(c *Context) any { return []byte("b") }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(stop)
+	wg.Wait()
+}
+
+// TestAllowedMissingPath 路径在任何方法下都不存在时返回 404,不构建 Allow。
+func TestAllowedMissingPath(t *testing.T) {
+	s := New()
+	s.Register("/user/:id<int>", func(c *Context) any { return []byte("ok") }, http.MethodGet)
+	w := serveTest(s, http.MethodPost, "/missing/1")
+	if w.Code != http.StatusNotFound || w.Header().Get(HeaderAllow) != "" {
+		t.Fatalf("missing path: got %d Allow=%q, want 404 without Allow", w.Code, w.Header().Get(HeaderAllow))
+	}
+	if s.exists("/missing/1") || !s.exists("/user/1") {
+		t.Error("exists: wrong result")
+	}
+	if n := testing.AllocsPerRun(100, func() { s.exists("/missing/1") }); n != 0 {
+		t.Errorf("exists on missing path: %v allocs, want 0", n)
+	}
+}
@@ -6,28 +6,44 @@ import (
 	"errors"
 	"net"
 	"net/http"
+	"net/netip"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/hwcer/cosgo/binder"
 	"github.com/hwcer/cosgo/registry"
 	"github.com/hwcer/cosgo/scc"
-	"github.com/hwcer/logger"
 )
 
 // Server is the top-level framework instance.
 type Server struct {
+	mu              sync.Mutex //保护路由注册、注销与替换
 	pool            sync.Pool
 	middleware      []MiddlewareFunc //全局中间件
 	Binder          binder.Binder    //默认序列化方式
 	Render          Render
+	Validator       Validator //c.BindAndValidate 使用的校验器,默认为内置的 TagValidator
 	Server          *http.Server
 	Registry        *registry.Registry
 	AcceptIgnore    map[string]bool    //响应协商时忽略的 MIME 类型（如 */*、form-urlencoded）
 	RequestDataType RequestDataTypeMap //使用GET获取数据时默认的查询方式
 	MaxBodySize     int64              //最大请求体大小，默认 10MB
 	MaxCacheSize    int64              //最大缓存大小，默认 1MB
+	Multipart       MultipartConfig    //multipart/form-data 上传配置
+	AutoOptions     bool               //路径已注册但未注册 OPTIONS 时,自动以 204 + Allow 响应 OPTIONS 请求，默认开启
+	PathPolicy      PathPolicy         //路径规范化策略,零值保持 registry 默认的兼容匹配
+	ETag            ETagMode           //handler 响应的 ETag 生成方式,默认不生成,参见 WithETag
+	TrustedProxies  []netip.Prefix     //可信代理,直连对端属于其中时才使用 Forwarded、X-Forwarded-* 头,参见 ParseTrustedProxies
+	routes          map[*registry.Node]*route
+	names           map[string]*route
+	parent          *Server //虚拟主机所属的 Server
+	notFound        HandlerFunc
+	fallback        []HandlerFunc
+	hosts           virtualHosts
+	serving         atomic.Bool                  //已开始处理请求,之后不能再添加新路由
+	produces        atomic.Pointer[produceCache] //未声明 Produces 时的协商候选,参见 defaultProduces
 }
 
 var (
@@ -51,7 +67,8 @@ const (
 // New creates an instance of Server.
 func New() (s *Server) {
 	s = &Server{
-		Binder: binder.New(binder.MIMEJSON),
+		Binder:    binder.New(binder.MIMEJSON),
+		Validator: NewValidator(),
 		Server: &http.Server{
 			ReadHeaderTimeout: defaultReadHeaderTimeout,
 			IdleTimeout:       defaultIdleTimeout,
@@ -60,6 +77,9 @@ func New() (s *Server) {
 		AcceptIgnore: map[string]bool{"*/*": true, binder.MIMEPOSTForm: true},
 		MaxBodySize:  10 << 20, // 10 MB
 		MaxCacheSize: 1 << 20,  // 1 MB
+		AutoOptions:  true,
+		routes:       make(map[*registry.Node]*route),
+		names:        make(map[string]*route),
 	}
 	s.Server.Handler = s
 	s.RequestDataType = defaultRequestDataType
@@ -78,20 +98,20 @@ func (srv *Server) Use(i MiddlewareFunc) {
 
 // GET registers a new GET Register for a path with matching handler in the Router
 // with optional Register-level middleware.
-func (srv *Server) GET(path string, h func(*Context) any) {
-	srv.Register(path, h, http.MethodGet)
+func (srv *Server) GET(path string, h func(*Context) any, options ...RouteOption) {
+	srv.register(path, h, routeOptions(http.MethodGet, options), nil)
 }
 
 // POST registers a new POST Register for a path with matching handler in the
 // Router with optional Register-level middleware.
-func (srv *Server) POST(path string, h func(*Context) any) {
-	srv.Register(path, h, http.MethodPost)
+func (srv *Server) POST(path string, h func(*Context) any, options ...RouteOption) {
+	srv.register(path, h, routeOptions(http.MethodPost, options), nil)
 }
 
 // Proxy 注册反向代理，通配路由匹配 prefix 下所有路径
 func (srv *Server) Proxy(prefix, address string, method ...string) *Proxy {
 	proxy := NewProxy(address)
-	srv.Register(wildcardRoute(prefix), proxy.Handle, method...)
+	srv.register(wildcardRoute(prefix), proxy.Handle, methodOptions(method), nil)
 	return proxy
 }
 
@@ -102,7 +122,7 @@ func (srv *Server) Static(prefix, root string, method ...string) *Static {
 	if len(method) == 0 {
 		method = []string{http.MethodGet, http.MethodHead}
 	}
-	srv.Register(wildcardRoute(prefix), static.Handle, method...)
+	srv.register(wildcardRoute(prefix), static.Handle, methodOptions(method), nil)
 	return static
 }
 
@@ -127,19 +147,54 @@ func (srv *Server) Handler(name ...string) (r *Handler) {
 	return service.GetHandler().(*Handler)
 }
 
-// Register AddTarget registers a new Register for an HTTP value and path with matching handler
-// in the Router with optional Register-level middleware.
-func (srv *Server) Register(route string, handler func(*Context) any, method ...string) {
-	service := srv.Service()
-	var err error
-	if len(method) == 0 {
-		err = service.Register(handler, route)
-	} else {
-		err = service.RegisterWithMethod(handler, method, route)
+// NotFound 设置未匹配路由时的 handler,返回值按 Accept 序列化并以 404 写出。
+// 全局中间件照常执行,日志、CORS 等对 404 同样生效。
+func (srv *Server) NotFound(h HandlerFunc) {
+	srv.notFound = h
+}
+
+// Fallback 追加未匹配路由时依次尝试的 handler,返回 ErrNotFound 表示未处理,交给下一个,
+// 返回其他 error 时交给 HTTPErrorHandler,全部未处理时交给 NotFound。例如先尝试静态文件,再返回 SPA 首页:
+//
+//	static := cosweb.NewStatic("./dist")
+//	s.Fallback(static.Handle, func(c *cosweb.Context) any { return c.File("./dist/index.html") })
+//	s.NotFound(func(c *cosweb.Context) any { return map[string]string{"error": "not found"} })
+func (srv *Server) Fallback(h ...HandlerFunc) {
+	for _, f := range h {
+		if f != nil {
+			srv.fallback = append(srv.fallback, f)
+		}
 	}
-	if err != nil {
-		logger.Alert(err)
+}
+
+// rootHandler 根服务的 Handler,用于序列化 NotFound/Fallback 的返回值,根服务不存在时使用默认 Handler
+func (srv *Server) rootHandler() *Handler {
+	if service, ok := srv.Registry.Get("/"); ok {
+		if h, ok := service.GetHandler().(*Handler); ok {
+			return h
+		}
 	}
+	return defaultHandler
+}
+
+// Register AddTarget registers a new Register for an HTTP value and path with matching handler
+// in the Router with optional Register-level middleware.
+// options: string、[]string 为 HTTP 方法(为空时匹配所有方法),RouteOption 为路由选项,例如:
+//
+//	s.Register("/user", h, http.MethodGet, http.MethodPost)
+//	s.Register("/user", h, WithMiddleware(auth), WithMethods(http.MethodPost))
+//	s.Register("/user", h, methods) // methods 为 []string,不能写作 methods...
+//
+// 其他类型在注册时记录错误并忽略,需要编译期检查时使用 Route
+func (srv *Server) Register(route string, handler func(*Context) any, options ...any) {
+	srv.register(route, handler, anyOptions(route, options), nil)
+}
+
+// Route 使用路由选项注册路由,未指定 WithMethods 时匹配所有方法:
+//
+//	s.Route("/user", h, WithMethods(http.MethodPost), WithMiddleware(auth))
+func (srv *Server) Route(path string, handler func(*Context) any, options ...RouteOption) {
+	srv.register(path, handler, options, nil)
 }
 
 // Acquire returns an empty `Context` instance from the pool.
@@ -152,13 +207,29 @@ func (srv *Server) Acquire(w http.ResponseWriter, r *http.Request) *Context {
 
 // Release returns the `Context` instance back to the pool.
 // You must call it after `AcquireContext()`.
+// 作为 context.Context 使用过的 Context 取消后丢弃,不放回缓存池
 func (srv *Server) Release(c *Context) {
-	c.release()
-	srv.pool.Put(c)
+	escaped := c.escaped()
+	c.release(escaped)
+	if !escaped {
+		srv.pool.Put(c)
+	}
 }
 
 // ServeHTTP implements `http.Handler` interface, which serves HTTP requests.
 func (srv *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if vh, sub := srv.virtualHost(r.Host); vh != nil {
+		vh.serve(w, r, sub)
+		return
+	}
+	srv.serve(w, r, "")
+}
+
+// serve 处理请求,sub 为通配虚拟主机匹配到的子域名
+func (srv *Server) serve(w http.ResponseWriter, r *http.Request, sub string) {
+	if !srv.serving.Load() {
+		srv.serving.Store(true)
+	}
 	scc.Add(1)
 	c := srv.Acquire(w, r)
 	defer func() {
@@ -173,11 +244,15 @@ func (srv *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		HTTPErrorHandler(c, "server stopped")
 		return
 	}
-	// 1. global middleware
-	funcs := append([]MiddlewareFunc{}, srv.middleware...)
+	// 1. global middleware (virtual host: parent global middleware first)
+	var funcs []MiddlewareFunc
+	if srv.parent != nil {
+		funcs = append(funcs, srv.parent.middleware...)
+	}
+	funcs = append(funcs, srv.middleware...)
 
 	// 2. path service handler middleware (e.g. /ws WebSocket middleware)
-	path := c.Request.URL.Path
+	path, redirect := srv.normalize(c)
 	var pathHandler *Handler
 	if service, _ := srv.Registry.Get(path); service != nil {
 		if h, ok := service.GetHandler().(*Handler); ok {
@@ -186,8 +261,12 @@ func (srv *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// 3. search route node
-	c.node, c.params = srv.Registry.Search(c.Request.Method, path)
+	// 3. search route node; redirect to canonical path when it matches a route
+	srv.search(c, path, sub)
+	if redirect != "" && (c.node != nil || len(c.allow) > 0) {
+		c.node, c.params, c.allow = nil, nil, nil
+		funcs = append(funcs, redirectPath(redirect))
+	}
 	var nodeHandler *Handler
 	if c.node != nil {
 		if h, ok := c.node.Handler().(*Handler); ok {
@@ -200,6 +279,14 @@ func (srv *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		funcs = append(funcs, nodeHandler.middleware...)
 	}
 
+	// 5. route middleware (group and route level)
+	if c.entry != nil {
+		funcs = append(funcs, c.entry.middleware...)
+	}
+	if c.entry != nil && len(c.entry.produces) > 0 {
+		funcs = append(funcs, acceptable)
+	}
+
 	c.dp.funcs = funcs
 
 	if err := c.doDispatch(); err != nil {
@@ -1,14 +1,24 @@
 package cosweb
 
 import (
+	"bufio"
+	"bytes"
+	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/hwcer/cosgo/binder"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zlib"
+	"github.com/klauspost/compress/zstd"
 )
 
 // newTestServer 返回一个 httptest.Server,包装当前 *Server。
@@ -282,3 +292,415 @@ func BenchmarkMiddlewareChain(b *testing.B) {
 		s.ServeHTTP(w, r)
 	}
 }
+
+// TestMethodNotAllowed 验证路径存在但方法不匹配时返回 405 + Allow,自动 OPTIONS 与 HEAD 回退 GET。
+func TestMethodNotAllowed(t *testing.T) {
+	s := New()
+	s.Register("/x", func(c *Context) any {
+		_ = c.String("x")
+		return nil
+	}, http.MethodGet, http.MethodPut)
+	ts := newTestServer(t, s)
+
+	req, _ := http.NewRequest(http.MethodPost, ts.URL+"/x", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("POST /x: expected 405, got %d", resp.StatusCode)
+	}
+	if got := resp.Header.Get(HeaderAllow); got != "GET, PUT, HEAD, OPTIONS" {
+		t.Errorf("unexpected Allow %q", got)
+	}
+
+	req, _ = http.NewRequest(http.MethodOptions, ts.URL+"/x", nil)
+	if resp, err = http.DefaultClient.Do(req); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent || resp.Header.Get(HeaderAllow) == "" {
+		t.Errorf("OPTIONS /x: expected 204 with Allow, got %d %q", resp.StatusCode, resp.Header.Get(HeaderAllow))
+	}
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodHead, "/x", nil))
+	if w.Code != http.StatusOK || w.Body.Len() != 0 {
+		t.Errorf("HEAD /x: expected 200 without body, got %d %q", w.Code, w.Body.String())
+	}
+
+	if resp, err = http.Get(ts.URL + "/y"); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("GET /y: expected 404, got %d", resp.StatusCode)
+	}
+}
+
+// TestNotFoundFallback 验证 Fallback 依次尝试、NotFound 以 404 写出,且全局中间件对 404 生效。
+func TestNotFoundFallback(t *testing.T) {
+	root := t.TempDir()
+	os.WriteFile(filepath.Join(root, "app.js"), []byte("js"), 0o644)
+	os.WriteFile(filepath.Join(root, "index.html"), []byte("spa"), 0o644)
+
+	s := New()
+	s.Use(func(c *Context, next Next) error {
+		c.Header().Set("X-Mw", "1")
+		return next()
+	})
+	s.GET("/api/x", func(c *Context) any { return "x" })
+	static := NewStatic(root)
+	s.Fallback(static.Handle, func(c *Context) any {
+		if strings.HasPrefix(c.Request.URL.Path, "/api/") {
+			return ErrNotFound
+		}
+		if err := c.File(filepath.Join(root, "index.html")); err != nil {
+			return err
+		}
+		return nil
+	})
+	s.NotFound(func(c *Context) any {
+		return map[string]string{"error": "not found"}
+	})
+
+	cases := []struct {
+		path   string
+		accept string
+		code   int
+		body   string
+	}{
+		{"/app.js", "", http.StatusOK, "js"},
+		{"/some/page", "", http.StatusOK, "spa"},
+		{"/api/missing", "application/json", http.StatusNotFound, `{"error":"not found"}`},
+	}
+	for _, tt := range cases {
+		r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		if tt.accept != "" {
+			r.Header.Set(HeaderAccept, tt.accept)
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		if w.Code != tt.code || w.Body.String() != tt.body {
+			t.Errorf("GET %s: got %d %q, want %d %q", tt.path, w.Code, w.Body.String(), tt.code, tt.body)
+		}
+		if w.Header().Get("X-Mw") != "1" {
+			t.Errorf("GET %s: global middleware should run", tt.path)
+		}
+	}
+	r := httptest.NewRequest(http.MethodGet, "/api/missing", nil)
+	r.Header.Set(HeaderAccept, "application/json")
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	if ct := w.Header().Get(HeaderContentType); !strings.HasPrefix(ct, "application/json") {
+		t.Errorf("NotFound content type: got %q", ct)
+	}
+}
+
+// TestRequestDecompression 验证 gzip/deflate/zstd 请求体透明解压,解压后大小受 MaxBodySize 限制,未知编码返回 415。
+func TestRequestDecompression(t *testing.T) {
+	s := New()
+	s.MaxBodySize = 1 << 10
+	s.POST("/save", func(c *Context) any {
+		var v map[string]string
+		if err := c.Bind(&v); err != nil {
+			return err
+		}
+		return []byte(v["name"])
+	})
+	compress := func(encoding string, data []byte) []byte {
+		var buf bytes.Buffer
+		var w io.WriteCloser
+		switch encoding {
+		case "gzip":
+			w = gzip.NewWriter(&buf)
+		case "deflate":
+			w = zlib.NewWriter(&buf)
+		case "zstd":
+			w, _ = zstd.NewWriter(&buf)
+		default:
+			return data
+		}
+		_, _ = w.Write(data)
+		_ = w.Close()
+		return buf.Bytes()
+	}
+	body := []byte(`{"name":"save-game"}`)
+	bomb := []byte(`{"name":"` + strings.Repeat("a", 4<<10) + `"}`)
+	cases := []struct {
+		encoding string
+		data     []byte
+		code     int
+		want     string
+	}{
+		{"gzip", body, http.StatusOK, "save-game"},
+		{"deflate", body, http.StatusOK, "save-game"},
+		{"zstd", body, http.StatusOK, "save-game"},
+		{"br", body, http.StatusUnsupportedMediaType, "unsupported content encoding"},
+		{"gzip", bomb, http.StatusRequestEntityTooLarge, "request body too large"},
+	}
+	for _, tt := range cases {
+		r := httptest.NewRequest(http.MethodPost, "/save", bytes.NewReader(compress(tt.encoding, tt.data)))
+		r.Header.Set(HeaderContentType, "application/json")
+		r.Header.Set(HeaderContentEncoding, tt.encoding)
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		if w.Code != tt.code || !strings.Contains(w.Body.String(), tt.want) {
+			t.Errorf("%s: got %d %q, want %d %q", tt.encoding, w.Code, w.Body.String(), tt.code, tt.want)
+		}
+	}
+}
+
+// TestBodyLimitOverrides 验证路由、Handler、请求级的请求体限制,以及 413 响应携带 Connection: close。
+func TestBodyLimitOverrides(t *testing.T) {
+	s := New()
+	s.MaxBodySize = 16
+	read := func(c *Context) any {
+		b, err := c.Buffer()
+		if err != nil {
+			return err
+		}
+		return []byte(strconv.Itoa(b.Len()))
+	}
+	s.POST("/small", read)
+	s.POST("/large", read, WithMaxBodySize(64))
+	api := s.Group("/api", func(c *Context, next Next) error {
+		c.SetMaxBodySize(32)
+		return next()
+	})
+	api.POST("/req", read, WithMaxBodySize(8))
+	svc := s.Handler("svc")
+	svc.SetMaxBodySize(48)
+	s.Service("svc").Register(func(c *Context) any { return read(c) }, "/read")
+
+	ts := newTestServer(t, s)
+	cases := []struct {
+		path string
+		size int
+		code int
+	}{
+		{"/small", 16, http.StatusOK},
+		{"/small", 17, http.StatusRequestEntityTooLarge},
+		{"/large", 64, http.StatusOK},
+		{"/large", 65, http.StatusRequestEntityTooLarge},
+		{"/api/req", 32, http.StatusOK},
+		{"/svc/read", 48, http.StatusOK},
+		{"/svc/read", 49, http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range cases {
+		resp, err := http.Post(ts.URL+tt.path, "application/octet-stream", strings.NewReader(strings.Repeat("a", tt.size)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != tt.code {
+			t.Errorf("%s %d: got %d, want %d", tt.path, tt.size, resp.StatusCode, tt.code)
+		}
+		if tt.code == http.StatusRequestEntityTooLarge && !resp.Close {
+			t.Errorf("%s %d: 413 without Connection: close", tt.path, tt.size)
+		}
+	}
+}
+
+// TestSpooledBody 超过 MaxCacheSize 的请求体写入临时文件,可重复读取,请求结束后删除
+func TestSpooledBody(t *testing.T) {
+	dir := t.TempDir()
+	s := New()
+	s.MaxCacheSize = 64
+	s.Multipart.TempDir = dir
+	type payload struct {
+		Name string `json:"name"`
+		Data string `json:"data"`
+	}
+	var spooled atomic.Bool
+	s.POST("/echo", func(c *Context) any {
+		var p1, p2 payload
+		if err := c.Bind(&p1); err != nil {
+			return err
+		}
+		if err := c.Bind(&p2); err != nil {
+			return err
+		}
+		b, err := c.Buffer()
+		if err != nil {
+			return err
+		}
+		raw, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			return err
+		}
+		if entries, _ := os.ReadDir(dir); len(entries) == 1 {
+			spooled.Store(true)
+		}
+		if p1 != p2 || !bytes.Equal(b.Bytes(), raw) {
+			return NewHTTPError(http.StatusInternalServerError, "body not replayable")
+		}
+		return []byte(p2.Name + ":" + strconv.Itoa(b.Len()))
+	}, WithMiddleware(func(c *Context, next Next) error {
+		// 中间件先读取一次请求体,如签名校验
+		if _, err := c.Buffer(); err != nil {
+			return err
+		}
+		return next()
+	}))
+
+	ts := newTestServer(t, s)
+	body := `{"name":"big","data":"` + strings.Repeat("x", 4096) + `"}`
+	resp, err := http.Post(ts.URL+"/echo", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(got) != "big:"+strconv.Itoa(len(body)) {
+		t.Fatalf("got %d %q", resp.StatusCode, got)
+	}
+	if !spooled.Load() {
+		t.Error("body above MaxCacheSize was not spooled to disk")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("temp file not removed: %d left", len(entries))
+	}
+}
+
+// TestResponseFlush 验证 StreamFlush 逐块发送、Response 可通过 http.ResponseController 使用,
+// 以及底层不支持 flush 时不提交响应头。
+func TestResponseFlush(t *testing.T) {
+	s := New()
+	next := make(chan struct{})
+	s.GET("/stream", func(c *Context) any {
+		if err := http.NewResponseController(c.Response).SetWriteDeadline(time.Now().Add(5 * time.Second)); err != nil {
+			return err
+		}
+		pr, pw := io.Pipe()
+		go func() {
+			_, _ = pw.Write([]byte("first\n"))
+			<-next // 客户端收到第一块后才写第二块
+			_, _ = pw.Write([]byte("second\n"))
+			_ = pw.Close()
+		}()
+		return c.StreamFlush(ContentTypeTextPlain, pr)
+	})
+	s.GET("/noflush", func(c *Context) any {
+		if err := c.Response.FlushError(); !errors.Is(err, http.ErrNotSupported) {
+			return []byte("expected ErrNotSupported")
+		}
+		return NewHTTPError(http.StatusTeapot, "not flushed")
+	})
+
+	ts := newTestServer(t, s)
+	resp, err := http.Get(ts.URL + "/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	br := bufio.NewReader(resp.Body)
+	if line, err := br.ReadString('\n'); err != nil || line != "first\n" {
+		t.Fatalf("first chunk: %q %v", line, err)
+	}
+	close(next)
+	if rest, _ := io.ReadAll(br); string(rest) != "second\n" {
+		t.Errorf("second chunk: %q", rest)
+	}
+
+	// 不实现 http.Flusher 的 ResponseWriter
+	w := struct{ http.ResponseWriter }{httptest.NewRecorder()}
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/noflush", nil))
+	if code := w.ResponseWriter.(*httptest.ResponseRecorder).Code; code != http.StatusTeapot {
+		t.Errorf("noflush: got %d", code)
+	}
+}
+
+// TestETag 验证服务与路由级 ETag、If-None-Match 返回 304,以及 If-Match 前置条件返回 412。
+func TestETag(t *testing.T) {
+	s := New()
+	s.ETag = ETagStrong
+	config := []byte(`{"version":1}`)
+	s.Register("/config", func(c *Context) any {
+		if c.Request.Method != http.MethodPut {
+			return config
+		}
+		if err := c.IfMatch(config); err != nil {
+			return err
+		}
+		b, err := c.Buffer()
+		if err != nil {
+			return err
+		}
+		config = append([]byte(nil), b.Bytes()...)
+		return config
+	}, http.MethodGet, http.MethodPut)
+	s.GET("/weak", func(c *Context) any {
+		return c.String("weak")
+	}, WithETag(ETagWeak))
+	s.GET("/off", func(c *Context) any {
+		return config
+	}, WithETag(ETagOff))
+	type item struct {
+		A string `json:"a" xml:"a"`
+	}
+	s.Register("/item", func(c *Context) any {
+		current := &item{A: "b"}
+		if c.Request.Method == http.MethodPut {
+			if err := c.IfMatch(current); err != nil {
+				return err
+			}
+		}
+		return current
+	}, http.MethodGet, http.MethodPut)
+
+	do := func(method, path, header, value, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		if header != "" {
+			r.Header.Set(header, value)
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		return w
+	}
+
+	w := do(http.MethodGet, "/config", "", "", "")
+	etag := w.Header().Get(HeaderETag)
+	if w.Code != http.StatusOK || !strings.HasPrefix(etag, `"`) {
+		t.Fatalf("GET: %d etag %q", w.Code, etag)
+	}
+	if w = do(http.MethodGet, "/config", HeaderIfNoneMatch, `"other", `+etag, ""); w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("If-None-Match: %d %q", w.Code, w.Body.String())
+	}
+	if w = do(http.MethodHead, "/config", HeaderIfNoneMatch, "W/"+etag, ""); w.Code != http.StatusNotModified {
+		t.Errorf("HEAD weak If-None-Match: %d", w.Code)
+	}
+	if w = do(http.MethodPut, "/config", HeaderIfMatch, `"stale"`, `{"version":2}`); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("stale If-Match: %d", w.Code)
+	}
+	if w = do(http.MethodPut, "/config", HeaderIfMatch, etag, `{"version":2}`); w.Code != http.StatusOK || w.Header().Get(HeaderETag) == etag {
+		t.Errorf("If-Match: %d etag %q", w.Code, w.Header().Get(HeaderETag))
+	}
+	if w = do(http.MethodGet, "/config", HeaderIfNoneMatch, etag, ""); w.Code != http.StatusOK || w.Body.String() != `{"version":2}` {
+		t.Errorf("after update: %d %q", w.Code, w.Body.String())
+	}
+
+	w = do(http.MethodGet, "/weak", "", "", "")
+	if weak := w.Header().Get(HeaderETag); !strings.HasPrefix(weak, `W/"`) {
+		t.Errorf("weak etag %q", weak)
+	} else if w = do(http.MethodGet, "/weak", HeaderIfNoneMatch, weak, ""); w.Code != http.StatusNotModified {
+		t.Errorf("weak If-None-Match: %d", w.Code)
+	}
+	if w = do(http.MethodGet, "/off", "", "", ""); w.Header().Get(HeaderETag) != "" {
+		t.Errorf("route ETagOff still sets %q", w.Header().Get(HeaderETag))
+	}
+
+	// GET 协商为 XML 得到的 ETag,PUT 按 JSON 协商时 IfMatch 仍能匹配
+	w = do(http.MethodGet, "/item", HeaderAccept, binder.MIMEXML, "")
+	if !strings.HasPrefix(w.Header().Get(HeaderContentType), binder.MIMEXML) {
+		t.Fatalf("GET /item: Content-Type %q", w.Header().Get(HeaderContentType))
+	}
+	if w = do(http.MethodPut, "/item", HeaderIfMatch, w.Header().Get(HeaderETag), ""); w.Code != http.StatusOK {
+		t.Errorf("If-Match from XML representation: %d", w.Code)
+	}
+	if w = do(http.MethodPut, "/item", HeaderIfMatch, `"stale"`, ""); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("stale If-Match on /item: %d", w.Code)
+	}
+}
@@ -0,0 +1,109 @@
+package cosweb
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/hwcer/cosgo/binder"
+)
+
+// spooledBody 超过 MaxCacheSize 的请求体,写入临时文件后可重复读取,release 时删除
+type spooledBody struct {
+	file *os.File
+	size int64
+}
+
+// reader 返回从头读取的新 reader,Close 不会关闭临时文件
+func (s *spooledBody) reader() io.ReadCloser {
+	return io.NopCloser(io.NewSectionReader(s.file, 0, s.size))
+}
+
+func (s *spooledBody) remove() {
+	name := s.file.Name()
+	_ = s.file.Close()
+	_ = os.Remove(name)
+}
+
+// loadBody 读取并保存请求体,只执行一次:
+// 不超过 MaxCacheSize 时保存在内存(c.body),否则写入临时文件(c.spool),超过 MaxBodySize 返回 413
+func (c *Context) loadBody() (err error) {
+	if c.body != nil || c.spool != nil {
+		return nil
+	}
+	if err = c.decodeBody(); err != nil {
+		return err
+	}
+	maxBodySize, maxCacheSize := c.MaxBodySize(), c.MaxCacheSize()
+	// 声明的长度已超限时不再读取
+	if c.Request.ContentLength > maxBodySize {
+		return c.bodyTooLarge()
+	}
+	if c.Request.Body == nil {
+		c.body = []byte{}
+		return nil
+	}
+	initCap := int64(256)
+	if cl := c.Request.ContentLength; cl > 0 {
+		initCap = min(cl, maxCacheSize+1)
+	}
+	b := bytes.NewBuffer(make([]byte, 0, initCap))
+	// 多读一字节以区分"恰好等于上限"和"超过上限"
+	reader := io.LimitReader(c.Request.Body, maxBodySize+1)
+	var n int64
+	if n, err = b.ReadFrom(io.LimitReader(reader, maxCacheSize+1)); err != nil {
+		return err
+	}
+	if n > maxBodySize {
+		return c.bodyTooLarge()
+	}
+	if n <= maxCacheSize {
+		if n == 0 {
+			if t := c.Request.Header.Get(HeaderContentType); strings.HasPrefix(strings.ToLower(t), binder.MIMEPOSTForm) {
+				b.WriteString(c.Request.URL.RawQuery)
+			}
+		}
+		c.body = b.Bytes()
+		return nil
+	}
+	if n, err = c.spoolBody(b, reader); err != nil {
+		return err
+	}
+	if n > maxBodySize {
+		c.spool.remove()
+		c.spool = nil
+		return c.bodyTooLarge()
+	}
+	return nil
+}
+
+// spoolBody 将已读取的 head 与剩余数据写入临时文件,返回总长度
+func (c *Context) spoolBody(head *bytes.Buffer, rest io.Reader) (n int64, err error) {
+	file, err := os.CreateTemp(c.Server.Multipart.TempDir, "cosweb-body-*")
+	if err != nil {
+		return 0, err
+	}
+	s := &spooledBody{file: file}
+	if n, err = head.WriteTo(file); err == nil {
+		var m int64
+		m, err = io.Copy(file, rest)
+		n += m
+	}
+	if err != nil {
+		s.remove()
+		return 0, err
+	}
+	s.size = n
+	c.spool = s
+	return n, nil
+}
+
+// rewindBody 将 c.Request.Body 重置为从头读取的请求体,供中间件与 handler 重复读取
+func (c *Context) rewindBody() {
+	if c.spool != nil {
+		c.Request.Body = c.spool.reader()
+	} else if c.body != nil {
+		c.Request.Body = io.NopCloser(bytes.NewReader(c.body))
+	}
+}
@@ -0,0 +1,196 @@
+package cosweb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrStreamingUnsupported ResponseWriter 不支持 http.Flusher,无法逐条推送
+	ErrStreamingUnsupported = NewHTTPError(http.StatusInternalServerError, "streaming unsupported")
+	// ErrResponseCommitted 响应已经开始写入或已被劫持
+	ErrResponseCommitted = errors.New("response already committed")
+	// ErrInvalidEvent event 或 id 中包含换行符
+	ErrInvalidEvent = errors.New("sse: event and id must not contain line breaks")
+)
+
+// SSE Server-Sent Events 写入器,由 c.SSE() 创建,每条消息写入后立即 flush,
+// 客户端断开或请求结束时 Done 关闭,之后的写入返回错误:
+//
+//	sse, err := c.SSE()
+//	if err != nil {
+//		return err
+//	}
+//	sse.KeepAlive(15 * time.Second)
+//	for {
+//		select {
+//		case <-sse.Done():
+//			return nil
+//		case m := <-updates:
+//			if err = sse.Send("state", m.ID, m); err != nil {
+//				return nil
+//			}
+//		}
+//	}
+type SSE struct {
+	c         *Context
+	mu        sync.Mutex
+	ctx       context.Context
+	cancel    context.CancelFunc
+	keepalive *time.Ticker
+	wg        sync.WaitGroup
+}
+
+// SSE 开始 text/event-stream 响应,写出响应头后 Response 视为已写入,
+// handler 之后返回的 reply 或 error 不会再写入响应;同一请求多次调用返回同一个写入器
+func (c *Context) SSE() (*SSE, error) {
+	if c.sse != nil {
+		return c.sse, nil
+	}
+	if !c.Response.CanWrite() {
+		return nil, ErrResponseCommitted
+	}
+	header := c.Header()
+	header.Set(HeaderContentType, string(ContentTypeTextEventStream))
+	header.Set(HeaderCacheControl, "no-cache")
+	header.Set("X-Accel-Buffering", "no") // 关闭 nginx 代理缓冲
+	header.Del(HeaderContentLength)
+	if err := c.Response.FlushError(); err != nil {
+		if errors.Is(err, http.ErrNotSupported) {
+			return nil, ErrStreamingUnsupported
+		}
+		return nil, err
+	}
+
+	s := &SSE{c: c}
+	s.ctx, s.cancel = context.WithCancel(c.Request.Context())
+	c.sse = s
+	return s, nil
+}
+
+// LastEventID 客户端断线重连时携带的最后一条消息 id,用于从断点继续推送
+func (s *SSE) LastEventID() string {
+	return s.c.Request.Header.Get(HeaderLastEventID)
+}
+
+// Done 客户端断开、写入失败或请求结束时关闭
+func (s *SSE) Done() <-chan struct{} {
+	return s.ctx.Done()
+}
+
+// Err Done 关闭的原因
+func (s *SSE) Err() error {
+	return s.ctx.Err()
+}
+
+// Send 推送一条消息,event、id 为空时省略;
+// data 为 string、[]byte 时原样发送,多行拆分为多个 data 字段,其他类型按 c.Accept() 序列化
+func (s *SSE) Send(event, id string, data any) error {
+	if strings.ContainsAny(event, "\r\n") || strings.ContainsAny(id, "\r\n\x00") {
+		return ErrInvalidEvent
+	}
+	var text string
+	switch v := data.(type) {
+	case nil:
+	case string:
+		text = v
+	case []byte:
+		text = string(v)
+	default:
+		b, err := s.c.Accept().Marshal(v)
+		if err != nil {
+			return err
+		}
+		text = string(b)
+	}
+	var buf bytes.Buffer
+	if id != "" {
+		buf.WriteString("id: " + id + "\n")
+	}
+	if event != "" {
+		buf.WriteString("event: " + event + "\n")
+	}
+	text = strings.ReplaceAll(strings.ReplaceAll(text, "\r\n", "\n"), "\r", "\n")
+	for _, line := range strings.Split(text, "\n") {
+		buf.WriteString("data: " + line + "\n")
+	}
+	buf.WriteByte('\n')
+	return s.write(buf.Bytes())
+}
+
+// Retry 通知客户端断线后的重连间隔
+func (s *SSE) Retry(d time.Duration) error {
+	return s.write([]byte("retry: " + strconv.FormatInt(d.Milliseconds(), 10) + "\n\n"))
+}
+
+// Comment 发送注释行,客户端忽略,可用于保持连接
+func (s *SSE) Comment(text string) error {
+	var buf bytes.Buffer
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r", ""), "\n") {
+		buf.WriteString(": " + line + "\n")
+	}
+	buf.WriteByte('\n')
+	return s.write(buf.Bytes())
+}
+
+// KeepAlive 每隔 d 发送一条注释,防止代理因空闲断开连接;重复调用时修改间隔
+func (s *SSE) KeepAlive(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ctx.Err() != nil {
+		return
+	}
+	if s.keepalive != nil {
+		s.keepalive.Reset(d)
+		return
+	}
+	ticker := time.NewTicker(d)
+	s.keepalive = ticker
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-ticker.C:
+				if s.Comment("keepalive") != nil {
+					return
+				}
+			}
+		}
+	}()
+}
+
+// Close 停止推送与 KeepAlive,请求结束时自动调用
+func (s *SSE) Close() {
+	s.cancel()
+	s.wg.Wait()
+	s.mu.Lock()
+	if s.keepalive != nil {
+		s.keepalive.Stop()
+	}
+	s.mu.Unlock()
+}
+
+func (s *SSE) write(b []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+	_, err := s.c.Response.Write(b)
+	if err == nil {
+		err = s.c.Response.FlushError()
+	}
+	if err != nil {
+		s.cancel()
+	}
+	return err
+}
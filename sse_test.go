@@ -0,0 +1,74 @@
+package cosweb
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSSE 验证事件格式、Last-Event-ID、handler 返回的错误不再写入,以及客户端断开后 Done 关闭。
+func TestSSE(t *testing.T) {
+	s := New()
+	s.GET("/events", func(c *Context) any {
+		sse, err := c.SSE()
+		if err != nil {
+			return err
+		}
+		_ = sse.Retry(3 * time.Second)
+		_ = sse.Send("state", "1", "line1\nline2")
+		_ = sse.Send("", "", map[string]int{"resume": len(sse.LastEventID())})
+		if err = sse.Send("bad\nevent", "", nil); !errors.Is(err, ErrInvalidEvent) {
+			return []byte("expected ErrInvalidEvent")
+		}
+		return errors.New("after stream")
+	})
+	done := make(chan error, 1)
+	s.GET("/live", func(c *Context) any {
+		sse, err := c.SSE()
+		if err != nil {
+			return err
+		}
+		sse.KeepAlive(10 * time.Millisecond)
+		select {
+		case <-sse.Done():
+			done <- sse.Err()
+		case <-time.After(5 * time.Second):
+			done <- errors.New("client disconnect not detected")
+		}
+		return nil
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/events", nil)
+	r.Header.Set(HeaderLastEventID, "42")
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	want := "retry: 3000\n\nid: 1\nevent: state\ndata: line1\ndata: line2\n\ndata: {\"resume\":2}\n\n"
+	if w.Code != http.StatusOK || w.Body.String() != want {
+		t.Fatalf("got %d %q, want %q", w.Code, w.Body.String(), want)
+	}
+	if ct := w.Header().Get(HeaderContentType); ct != string(ContentTypeTextEventStream) || !w.Flushed {
+		t.Errorf("Content-Type %q, flushed %v", ct, w.Flushed)
+	}
+
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+"/live", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 64)
+	if n, _ := resp.Body.Read(buf); !strings.HasPrefix(string(buf[:n]), ": keepalive\n") {
+		t.Errorf("keepalive: got %q", buf[:n])
+	}
+	cancel()
+	resp.Body.Close()
+	if err = <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Done after disconnect: %v", err)
+	}
+}
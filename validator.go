@@ -0,0 +1,271 @@
+package cosweb
+
+import (
+	"fmt"
+	"net/http"
+	"net/mail"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Validator 请求参数校验,c.BindAndValidate 绑定完成后调用
+type Validator interface {
+	Validate(i any) error
+}
+
+// FieldError 单个字段的校验错误
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+// ValidationErrors 全部未通过校验的字段
+type ValidationErrors []*FieldError
+
+func (ve ValidationErrors) Error() string {
+	msgs := make([]string, len(ve))
+	for i, e := range ve {
+		msgs[i] = e.Message
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// ValidateRule 校验规则,param 为 min=1 中的 1,通过时返回 true
+type ValidateRule func(v reflect.Value, param string) bool
+
+// TagValidator 基于 validate 标签的内置校验器:
+//
+//	Name  string `json:"name" validate:"required,max=64"`
+//	Age   int    `json:"age" validate:"min=1,max=150"`
+//	Email string `json:"email" validate:"omitempty,email"`
+//	Role  string `json:"role" validate:"oneof=admin user"`
+//
+// min/max 对字符串比较字符数,对切片、map 比较长度,对数字比较数值;嵌套结构体递归校验。
+type TagValidator struct {
+	rules map[string]ValidateRule
+	cache sync.Map // reflect.Type → []validateField
+}
+
+type validateField struct {
+	index []int
+	name  string
+	rules []validateTag
+	dive  bool //结构体字段,递归校验
+}
+
+type validateTag struct {
+	name  string
+	param string
+	rule  ValidateRule
+}
+
+// NewValidator 创建内置校验器
+func NewValidator() *TagValidator {
+	v := &TagValidator{rules: map[string]ValidateRule{}}
+	v.RegisterRule("required", func(v reflect.Value, _ string) bool { return !v.IsZero() })
+	v.RegisterRule("min", func(v reflect.Value, p string) bool { return compareRule(v, p, 1) })
+	v.RegisterRule("max", func(v reflect.Value, p string) bool { return compareRule(v, p, -1) })
+	v.RegisterRule("email", func(v reflect.Value, _ string) bool {
+		if v.Kind() != reflect.String {
+			return false
+		}
+		addr, err := mail.ParseAddress(v.String())
+		return err == nil && addr.Address == v.String()
+	})
+	v.RegisterRule("oneof", func(v reflect.Value, p string) bool {
+		s := fmt.Sprint(v.Interface())
+		for _, o := range strings.Fields(p) {
+			if o == s {
+				return true
+			}
+		}
+		return false
+	})
+	return v
+}
+
+// RegisterRule 注册自定义规则,需在使用前注册
+func (tv *TagValidator) RegisterRule(name string, rule ValidateRule) {
+	tv.rules[name] = rule
+}
+
+// Validate 校验结构体,返回全部未通过的字段
+func (tv *TagValidator) Validate(i any) error {
+	v := reflect.Indirect(reflect.ValueOf(i))
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+	var errs ValidationErrors
+	if err := tv.validate(v, "", &errs); err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (tv *TagValidator) validate(v reflect.Value, prefix string, errs *ValidationErrors) error {
+	fields, err := tv.fields(v.Type())
+	if err != nil {
+		return err
+	}
+	for _, f := range fields {
+		fv := v.FieldByIndex(f.index)
+		name := prefix + f.name
+		rv := fv
+		if rv.Kind() == reflect.Pointer && !rv.IsNil() {
+			rv = rv.Elem()
+		}
+		for _, r := range f.rules {
+			if r.name == "omitempty" {
+				if fv.IsZero() {
+					break
+				}
+				continue
+			}
+			if !r.rule(rv, r.param) {
+				*errs = append(*errs, &FieldError{Field: name, Rule: r.name, Param: r.param, Message: fieldMessage(name, r)})
+				break
+			}
+		}
+		if f.dive && rv.Kind() == reflect.Struct {
+			if name != "" {
+				name += "."
+			}
+			if err = tv.validate(rv, name, errs); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// fields 解析并缓存结构体的校验规则
+func (tv *TagValidator) fields(t reflect.Type) ([]validateField, error) {
+	if v, ok := tv.cache.Load(t); ok {
+		return v.([]validateField), nil
+	}
+	var fields []validateField
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		f := validateField{index: []int{i}, name: fieldName(sf)}
+		ft := sf.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		f.dive = ft.Kind() == reflect.Struct && ft != timeType
+		tag := sf.Tag.Get("validate")
+		if tag == "-" {
+			continue
+		}
+		for _, s := range strings.Split(tag, ",") {
+			if s = strings.TrimSpace(s); s == "" {
+				continue
+			}
+			name, param, _ := strings.Cut(s, "=")
+			r := validateTag{name: name, param: param}
+			if name != "omitempty" {
+				if r.rule = tv.rules[name]; r.rule == nil {
+					return nil, NewHTTPError500(fmt.Sprintf("unknown validate rule %q on %s.%s", name, t.Name(), sf.Name))
+				}
+			}
+			f.rules = append(f.rules, r)
+		}
+		if len(f.rules) > 0 || f.dive {
+			if sf.Anonymous && f.dive {
+				f.name = ""
+			}
+			fields = append(fields, f)
+		}
+	}
+	tv.cache.Store(t, fields)
+	return fields, nil
+}
+
+// fieldName 错误中使用的字段名,优先使用客户端可见的标签名
+func fieldName(sf reflect.StructField) string {
+	for _, tag := range []string{"json", "form", "query", "param", "header", "cookie"} {
+		if name, _, _ := strings.Cut(sf.Tag.Get(tag), ","); name != "" && name != "-" {
+			return name
+		}
+	}
+	return sf.Name
+}
+
+func fieldMessage(name string, r validateTag) string {
+	switch r.name {
+	case "required":
+		return name + " is required"
+	case "min":
+		return fmt.Sprintf("%s must be at least %s", name, r.param)
+	case "max":
+		return fmt.Sprintf("%s must be at most %s", name, r.param)
+	case "email":
+		return name + " must be a valid email address"
+	case "oneof":
+		return fmt.Sprintf("%s must be one of [%s]", name, r.param)
+	default:
+		return fmt.Sprintf("%s failed on %s", name, r.name)
+	}
+}
+
+// compareRule min(sign=1)/max(sign=-1) 比较
+func compareRule(v reflect.Value, param string, sign int) bool {
+	var n float64
+	switch v.Kind() {
+	case reflect.String:
+		n = float64(utf8.RuneCountInString(v.String()))
+	case reflect.Slice, reflect.Map, reflect.Array:
+		n = float64(v.Len())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n = float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n = float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		n = v.Float()
+	default:
+		return false
+	}
+	p, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return false
+	}
+	if sign > 0 {
+		return n >= p
+	}
+	return n <= p
+}
+
+// BindAndValidate 使用 BindAll 绑定后调用 Server.Validator 校验,
+// 未通过时返回 422 HTTPError,Errors 中列出全部未通过的字段
+func (c *Context) BindAndValidate(i any) error {
+	if err := c.BindAll(i); err != nil {
+		return err
+	}
+	if c.Server.Validator == nil {
+		return ErrValidatorNotRegistered
+	}
+	err := c.Server.Validator.Validate(i)
+	if err == nil {
+		return nil
+	}
+	switch v := err.(type) {
+	case *HTTPError:
+		return v
+	case ValidationErrors:
+		he := NewHTTPError(http.StatusUnprocessableEntity, "validation failed")
+		he.Errors = v
+		return he
+	default:
+		return NewHTTPError(http.StatusUnprocessableEntity, err)
+	}
+}
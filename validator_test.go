@@ -0,0 +1,67 @@
+package cosweb
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestBindAndValidate 验证内置校验器以 422 返回全部未通过的字段。
+func TestBindAndValidate(t *testing.T) {
+	type Address struct {
+		City string `json:"city" validate:"required"`
+	}
+	type Req struct {
+		Name    string   `json:"name" validate:"required,max=4"`
+		Age     int      `json:"age" validate:"min=1,max=150"`
+		Email   string   `json:"email" validate:"omitempty,email"`
+		Role    string   `query:"role" validate:"oneof=admin user"`
+		Address *Address `json:"address"`
+	}
+	s := New()
+	s.Register("/user", func(c *Context) any {
+		var req Req
+		if err := c.BindAndValidate(&req); err != nil {
+			return err
+		}
+		return []byte("ok")
+	}, http.MethodPost)
+
+	post := func(query, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/user"+query, strings.NewReader(body))
+		r.Header.Set(HeaderContentType, "application/json")
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		return w
+	}
+	if w := post("?role=admin", `{"name":"bob","age":20,"address":{"city":"x"}}`); w.Code != http.StatusOK {
+		t.Fatalf("valid: %d %q", w.Code, w.Body.String())
+	}
+	w := post("?role=root", `{"name":"alice","age":0,"email":"bad","address":{}}`)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("invalid: got %d, want 422: %q", w.Code, w.Body.String())
+	}
+	var res struct {
+		Message string       `json:"message"`
+		Errors  []FieldError `json:"errors"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatalf("decode %q: %v", w.Body.String(), err)
+	}
+	var got []string
+	for _, e := range res.Errors {
+		got = append(got, e.Field+":"+e.Rule)
+	}
+	want := []string{"name:max", "age:min", "email:email", "role:oneof", "address.city:required"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("errors: got %v, want %v", got, want)
+	}
+
+	s.Validator = nil
+	if w = post("", `{}`); w.Code != http.StatusInternalServerError {
+		t.Errorf("no validator: got %d, want 500", w.Code)
+	}
+}
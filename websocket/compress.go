@@ -0,0 +1,172 @@
+package websocket
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/flate"
+)
+
+// permessage-deflate(RFC 7692),双方均不接管上下文:每条消息独立压缩,连接不持有压缩字典
+
+const extensionDeflate = "permessage-deflate"
+
+// deflateTail 每条消息压缩后去掉、解压前补回的同步刷新块尾部
+var deflateTail = []byte{0x00, 0x00, 0xff, 0xff}
+
+// deflateFinal 解压时追加的空结束块,让 flate reader 以 io.EOF 结束
+var deflateFinal = []byte{0x00, 0x00, 0xff, 0xff, 0x01, 0x00, 0x00, 0xff, 0xff}
+
+var (
+	flateWriterPools sync.Map // level -> *sync.Pool
+	flateReaderPool  = sync.Pool{New: func() any {
+		return flate.NewReader(nil)
+	}}
+)
+
+func flateWriterPool(level int) *sync.Pool {
+	if p, ok := flateWriterPools.Load(level); ok {
+		return p.(*sync.Pool)
+	}
+	p, _ := flateWriterPools.LoadOrStore(level, &sync.Pool{New: func() any {
+		w, err := flate.NewWriter(io.Discard, level)
+		if err != nil {
+			w, _ = flate.NewWriter(io.Discard, flate.DefaultCompression)
+		}
+		return w
+	}})
+	return p.(*sync.Pool)
+}
+
+// flateWriter 压缩写入的数据,Close 时去掉同步刷新尾部并结束消息
+type flateWriter struct {
+	mw    *messageWriter
+	tw    truncWriter
+	fw    *flate.Writer
+	level int
+}
+
+func newFlateWriter(mw *messageWriter, level int) *flateWriter {
+	if level == 0 {
+		level = flate.DefaultCompression
+	}
+	w := &flateWriter{mw: mw, level: level}
+	w.tw.w = mw
+	w.fw = flateWriterPool(level).Get().(*flate.Writer)
+	w.fw.Reset(&w.tw)
+	return w
+}
+
+func (w *flateWriter) Write(p []byte) (int, error) {
+	if w.fw == nil {
+		return 0, io.ErrClosedPipe
+	}
+	return w.fw.Write(p)
+}
+
+func (w *flateWriter) Close() error {
+	if w.fw == nil {
+		return nil
+	}
+	err := w.fw.Flush()
+	w.fw.Reset(io.Discard)
+	flateWriterPool(w.level).Put(w.fw)
+	w.fw = nil
+	if e := w.mw.Close(); err == nil {
+		err = e
+	}
+	return err
+}
+
+// truncWriter 保留最后 4 字节不写出,丢弃同步刷新产生的 00 00 ff ff
+type truncWriter struct {
+	w    io.Writer
+	tail [4]byte
+	n    int
+}
+
+func (w *truncWriter) Write(p []byte) (int, error) {
+	total := len(p)
+	// 先填满尾部缓存
+	if w.n < len(w.tail) {
+		m := copy(w.tail[w.n:], p)
+		w.n += m
+		p = p[m:]
+		if len(p) == 0 {
+			return total, nil
+		}
+	}
+	// 尾部缓存 + p 中,除最后 4 字节外全部写出
+	m := len(w.tail)
+	if len(p) < m {
+		m = len(p)
+	}
+	if _, err := w.w.Write(w.tail[:m]); err != nil {
+		return 0, err
+	}
+	copy(w.tail[:], w.tail[m:])
+	keep := len(p) - len(w.tail)
+	if keep > 0 {
+		if _, err := w.w.Write(p[:keep]); err != nil {
+			return 0, err
+		}
+		p = p[keep:]
+	}
+	copy(w.tail[len(w.tail)-len(p):], p)
+	return total, nil
+}
+
+// inflate 解压一条消息,长度超过 readLimit 时返回 ErrReadLimit
+func (c *Conn) inflate(data []byte) ([]byte, error) {
+	fr := flateReaderPool.Get().(io.ReadCloser)
+	defer flateReaderPool.Put(fr)
+	_ = fr.(flate.Resetter).Reset(io.MultiReader(bytes.NewReader(data), bytes.NewReader(deflateFinal)), nil)
+	var r io.Reader = fr
+	if c.readLimit > 0 {
+		r = io.LimitReader(fr, c.readLimit+1)
+	}
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(r); err != nil {
+		return nil, &CloseError{Code: CloseInvalidFramePayloadData, Text: "invalid compressed data"}
+	}
+	if c.readLimit > 0 && int64(buf.Len()) > c.readLimit {
+		return nil, ErrReadLimit
+	}
+	return buf.Bytes(), nil
+}
+
+// negotiateDeflate 在客户端的扩展列表中查找可接受的 permessage-deflate 提议,返回响应头中的扩展声明
+func negotiateDeflate(offers []string) (string, bool) {
+	for _, header := range offers {
+		for _, offer := range strings.Split(header, ",") {
+			params := strings.Split(offer, ";")
+			if !strings.EqualFold(strings.TrimSpace(params[0]), extensionDeflate) {
+				continue
+			}
+			ok := true
+			for _, p := range params[1:] {
+				k, v, _ := strings.Cut(strings.TrimSpace(p), "=")
+				switch strings.ToLower(strings.TrimSpace(k)) {
+				case "server_no_context_takeover", "client_no_context_takeover":
+				case "client_max_window_bits":
+					// 客户端窗口由客户端决定,服务端按 32KB 窗口解压,兼容任意值
+				case "server_max_window_bits":
+					// 压缩器固定使用 32KB 窗口,只接受 15
+					ok = ok && strings.Trim(strings.TrimSpace(v), `"`) == "15"
+				default:
+					ok = false
+				}
+				// 任一参数不可接受时拒绝整个提议,继续尝试下一个
+				if !ok {
+					break
+				}
+			}
+			if ok {
+				return extensionDeflate + "; server_no_context_takeover; client_no_context_takeover", true
+			}
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,25 @@
+package websocket
+
+import "testing"
+
+// TestNegotiateDeflate 任一参数不可接受时拒绝该提议,并继续尝试后续提议。
+func TestNegotiateDeflate(t *testing.T) {
+	cases := []struct {
+		offers []string
+		want   bool
+	}{
+		{[]string{"permessage-deflate"}, true},
+		{[]string{"permessage-deflate; client_max_window_bits"}, true},
+		{[]string{"permessage-deflate; server_max_window_bits=15"}, true},
+		{[]string{"permessage-deflate; server_max_window_bits=10"}, false},
+		{[]string{"permessage-deflate; x-unknown; server_max_window_bits=15"}, false},
+		{[]string{"permessage-deflate; server_max_window_bits=10; client_no_context_takeover"}, false},
+		{[]string{"permessage-deflate; x-unknown, permessage-deflate"}, true},
+		{[]string{"x-webkit-deflate-frame"}, false},
+	}
+	for _, tt := range cases {
+		if _, ok := negotiateDeflate(tt.offers); ok != tt.want {
+			t.Errorf("%q: got %v, want %v", tt.offers, ok, tt.want)
+		}
+	}
+}
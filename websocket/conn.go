@@ -0,0 +1,608 @@
+// Package websocket RFC 6455 WebSocket 实现,支持分片、ping/pong、关闭握手与 permessage-deflate(RFC 7692)。
+//
+// 服务端通过 cosweb.Context.Upgrade 或 Upgrader.Upgrade 建立连接;
+// NewConn 直接包装已建立的 net.Conn,可配合 net.Pipe 测试。
+//
+// 同一时刻只允许一个 goroutine 读、一个 goroutine 写(WriteMessage/NextWriter);
+// Ping、WriteClose 与读取时自动回复的 pong、close 可以与它们并发调用。
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// 消息类型,即帧的 opcode
+const (
+	TextMessage   = 1
+	BinaryMessage = 2
+	CloseMessage  = 8
+	PingMessage   = 9
+	PongMessage   = 10
+
+	continuationFrame = 0
+)
+
+// 关闭状态码,参见 RFC 6455 7.4.1
+const (
+	CloseNormalClosure           = 1000
+	CloseGoingAway               = 1001
+	CloseProtocolError           = 1002
+	CloseUnsupportedData         = 1003
+	CloseNoStatusReceived        = 1005
+	CloseAbnormalClosure         = 1006
+	CloseInvalidFramePayloadData = 1007
+	ClosePolicyViolation         = 1008
+	CloseMessageTooBig           = 1009
+	CloseMandatoryExtension      = 1010
+	CloseInternalServerErr       = 1011
+)
+
+const (
+	// DefaultReadLimit 单条消息默认最大长度(解压后)
+	DefaultReadLimit = 32 << 20
+	// DefaultBufferSize 默认读写缓冲大小,写入时也是单个分片的最大长度
+	DefaultBufferSize = 4096
+
+	maxControlPayload = 125
+	maxFrameHeader    = 14
+)
+
+var (
+	// ErrReadLimit 消息超过读取上限,连接以 1009 关闭
+	ErrReadLimit = errors.New("websocket: read limit exceeded")
+	// ErrCloseSent 已发送关闭帧,不能再写入数据
+	ErrCloseSent = errors.New("websocket: close sent")
+	// ErrWriterBusy 上一个 NextWriter 尚未 Close
+	ErrWriterBusy = errors.New("websocket: previous writer not closed")
+)
+
+// CloseError 收到对端关闭帧,或因协议错误关闭连接
+type CloseError struct {
+	Code int
+	Text string
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("websocket: close %d %s", e.Code, e.Text)
+}
+
+// IsCloseError err 是否为指定状态码之一的 CloseError
+func IsCloseError(err error, codes ...int) bool {
+	var ce *CloseError
+	if !errors.As(err, &ce) {
+		return false
+	}
+	for _, code := range codes {
+		if ce.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// Option NewConn 的可选参数
+type Option func(*Conn)
+
+// WithReadLimit 设置单条消息最大长度,参见 Conn.SetReadLimit
+func WithReadLimit(n int64) Option {
+	return func(c *Conn) {
+		c.readLimit = n
+	}
+}
+
+// WithBufferSize 设置读写缓冲大小,写缓冲同时决定分片大小,0 使用 DefaultBufferSize
+func WithBufferSize(read, write int) Option {
+	return func(c *Conn) {
+		if read > 0 {
+			c.readSize = read
+		}
+		if write > 0 {
+			c.writeSize = write
+		}
+	}
+}
+
+// WithCompression 启用已协商的 permessage-deflate(无上下文接管),level 为 0 时使用默认级别
+func WithCompression(level int) Option {
+	return func(c *Conn) {
+		c.compress = true
+		c.compressLevel = level
+	}
+}
+
+// WithReader 使用握手时已缓存数据的 bufio.Reader 读取,避免丢失紧跟握手发送的帧
+func WithReader(br *bufio.Reader) Option {
+	return func(c *Conn) {
+		c.br = br
+	}
+}
+
+// Conn WebSocket 连接
+type Conn struct {
+	conn          net.Conn
+	server        bool
+	subprotocol   string
+	compress      bool
+	compressLevel int
+	readSize      int
+	writeSize     int
+
+	// 读
+	br          *bufio.Reader
+	readLimit   int64
+	readErr     error
+	pingHandler func(data []byte) error
+	pongHandler func(data []byte) error
+
+	// 写,wmu 保护单个帧的写入,使控制帧可以插入分片之间
+	wmu       sync.Mutex
+	closeSent bool
+	writing   bool
+	writeErr  error
+}
+
+// NewConn 包装已完成握手的连接,server 为 true 时按服务端规则(要求客户端帧加掩码、发送不加掩码)读写
+func NewConn(conn net.Conn, server bool, options ...Option) *Conn {
+	c := &Conn{
+		conn:      conn,
+		server:    server,
+		readLimit: DefaultReadLimit,
+		readSize:  DefaultBufferSize,
+		writeSize: DefaultBufferSize,
+	}
+	for _, f := range options {
+		f(c)
+	}
+	if c.br == nil {
+		c.br = bufio.NewReaderSize(conn, c.readSize)
+	}
+	c.pingHandler = func(data []byte) error {
+		if err := c.WriteControl(PongMessage, data); err != nil && !errors.Is(err, ErrCloseSent) {
+			return err
+		}
+		return nil
+	}
+	return c
+}
+
+// Subprotocol 握手协商的子协议
+func (c *Conn) Subprotocol() string {
+	return c.subprotocol
+}
+
+// NetConn 底层连接
+func (c *Conn) NetConn() net.Conn {
+	return c.conn
+}
+
+func (c *Conn) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	return c.conn.SetWriteDeadline(t)
+}
+
+// SetReadLimit 设置单条消息最大长度(permessage-deflate 时为解压后长度),超过时以 1009 关闭连接
+func (c *Conn) SetReadLimit(n int64) {
+	c.readLimit = n
+}
+
+// SetPingHandler 收到 ping 时调用,默认回复相同内容的 pong;在读取的 goroutine 中执行
+func (c *Conn) SetPingHandler(h func(data []byte) error) {
+	if h == nil {
+		h = func([]byte) error { return nil }
+	}
+	c.pingHandler = h
+}
+
+// SetPongHandler 收到 pong 时调用,可用于刷新读超时;在读取的 goroutine 中执行
+func (c *Conn) SetPongHandler(h func(data []byte) error) {
+	c.pongHandler = h
+}
+
+// Close 立即关闭底层连接,不发送关闭帧;正常关闭请先调用 WriteClose
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+// ---------------------------------------------------------------- 读
+
+// frameHeader 帧头
+type frameHeader struct {
+	fin    bool
+	rsv1   bool
+	opcode int
+	length int64
+	mask   [4]byte
+	masked bool
+}
+
+// ReadMessage 读取下一条完整的数据消息,分片自动合并,期间收到的控制帧交给对应 handler 处理;
+// 收到关闭帧时回复关闭帧并返回 *CloseError,之后的调用返回同一个错误
+func (c *Conn) ReadMessage() (messageType int, data []byte, err error) {
+	if c.readErr != nil {
+		return 0, nil, c.readErr
+	}
+	var buf bytes.Buffer
+	compressed := false
+	for {
+		var h frameHeader
+		if h, err = c.readHeader(); err != nil {
+			return 0, nil, c.readFail(err)
+		}
+		if h.opcode >= CloseMessage {
+			if err = c.readControl(h); err != nil {
+				return 0, nil, c.readFail(err)
+			}
+			continue
+		}
+		if h.opcode == continuationFrame {
+			if messageType == 0 {
+				return 0, nil, c.readFail(protocolError("continuation frame without message"))
+			}
+			if h.rsv1 {
+				return 0, nil, c.readFail(protocolError("RSV1 set on continuation frame"))
+			}
+		} else {
+			if messageType != 0 {
+				return 0, nil, c.readFail(protocolError("new message before previous message finished"))
+			}
+			messageType, compressed = h.opcode, h.rsv1
+		}
+		if c.readLimit > 0 && int64(buf.Len())+h.length > c.readLimit {
+			return 0, nil, c.readFail(ErrReadLimit)
+		}
+		if err = c.readPayload(h, &buf); err != nil {
+			return 0, nil, c.readFail(err)
+		}
+		if h.fin {
+			break
+		}
+	}
+	data = buf.Bytes()
+	if compressed {
+		if data, err = c.inflate(data); err != nil {
+			return 0, nil, c.readFail(err)
+		}
+	}
+	if messageType == TextMessage && !utf8.Valid(data) {
+		return 0, nil, c.readFail(&CloseError{Code: CloseInvalidFramePayloadData, Text: "invalid utf-8"})
+	}
+	return messageType, data, nil
+}
+
+func (c *Conn) readHeader() (h frameHeader, err error) {
+	var b [8]byte
+	if _, err = io.ReadFull(c.br, b[:2]); err != nil {
+		return
+	}
+	h.fin = b[0]&0x80 != 0
+	h.rsv1 = b[0]&0x40 != 0
+	h.opcode = int(b[0] & 0x0f)
+	h.masked = b[1]&0x80 != 0
+	h.length = int64(b[1] & 0x7f)
+	if b[0]&0x30 != 0 {
+		return h, protocolError("unexpected RSV bits")
+	}
+	if h.rsv1 && (!c.compress || h.opcode >= CloseMessage) {
+		return h, protocolError("unexpected RSV1 bit")
+	}
+	switch h.opcode {
+	case continuationFrame, TextMessage, BinaryMessage:
+	case CloseMessage, PingMessage, PongMessage:
+		if !h.fin || h.length > maxControlPayload {
+			return h, protocolError("invalid control frame")
+		}
+	default:
+		return h, protocolError(fmt.Sprintf("unknown opcode %d", h.opcode))
+	}
+	switch h.length {
+	case 126:
+		if _, err = io.ReadFull(c.br, b[:2]); err != nil {
+			return
+		}
+		h.length = int64(binary.BigEndian.Uint16(b[:2]))
+	case 127:
+		if _, err = io.ReadFull(c.br, b[:8]); err != nil {
+			return
+		}
+		if b[0]&0x80 != 0 {
+			return h, protocolError("invalid payload length")
+		}
+		h.length = int64(binary.BigEndian.Uint64(b[:8]))
+	}
+	// 客户端发送的帧必须加掩码,服务端发送的帧不能加掩码
+	if h.masked != c.server {
+		return h, protocolError("bad mask")
+	}
+	if h.masked {
+		if _, err = io.ReadFull(c.br, h.mask[:]); err != nil {
+			return
+		}
+	}
+	return h, nil
+}
+
+func (c *Conn) readPayload(h frameHeader, buf *bytes.Buffer) error {
+	start := buf.Len()
+	if _, err := buf.ReadFrom(io.LimitReader(c.br, h.length)); err != nil {
+		return err
+	}
+	if int64(buf.Len()-start) != h.length {
+		return io.ErrUnexpectedEOF
+	}
+	if h.masked {
+		maskBytes(h.mask, buf.Bytes()[start:])
+	}
+	return nil
+}
+
+// readControl 处理控制帧
+func (c *Conn) readControl(h frameHeader) error {
+	var buf bytes.Buffer
+	if err := c.readPayload(h, &buf); err != nil {
+		return err
+	}
+	data := buf.Bytes()
+	switch h.opcode {
+	case PingMessage:
+		return c.pingHandler(data)
+	case PongMessage:
+		if c.pongHandler != nil {
+			return c.pongHandler(data)
+		}
+		return nil
+	}
+	ce := &CloseError{Code: CloseNoStatusReceived}
+	switch {
+	case len(data) == 1:
+		return protocolError("invalid close payload")
+	case len(data) >= 2:
+		ce.Code = int(binary.BigEndian.Uint16(data))
+		ce.Text = string(data[2:])
+		if !validCloseCode(ce.Code) || !utf8.Valid(data[2:]) {
+			return protocolError("invalid close payload")
+		}
+	}
+	// 回复关闭帧后关闭连接,对端也已不再发送数据
+	if ce.Code == CloseNoStatusReceived {
+		_ = c.WriteControl(CloseMessage, nil)
+	} else {
+		_ = c.WriteControl(CloseMessage, FormatCloseMessage(ce.Code, ""))
+	}
+	_ = c.conn.Close()
+	return ce
+}
+
+// readFail 记录读取错误;协议错误与超限时发送对应的关闭帧并关闭连接
+func (c *Conn) readFail(err error) error {
+	var ce *CloseError
+	switch {
+	case errors.As(err, &ce) && ce.Code != CloseNoStatusReceived && c.readErr == nil:
+		if ce.Code == CloseProtocolError || ce.Code == CloseInvalidFramePayloadData {
+			_ = c.WriteControl(CloseMessage, FormatCloseMessage(ce.Code, ce.Text))
+			_ = c.conn.Close()
+		}
+	case errors.Is(err, ErrReadLimit):
+		_ = c.WriteControl(CloseMessage, FormatCloseMessage(CloseMessageTooBig, ""))
+		_ = c.conn.Close()
+	case errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF):
+		err = &CloseError{Code: CloseAbnormalClosure, Text: err.Error()}
+	}
+	c.readErr = err
+	return err
+}
+
+func protocolError(text string) error {
+	return &CloseError{Code: CloseProtocolError, Text: text}
+}
+
+// validCloseCode 关闭帧中允许出现的状态码
+func validCloseCode(code int) bool {
+	switch {
+	case code >= 1000 && code <= 1003, code >= 1007 && code <= 1011:
+		return true
+	case code >= 3000 && code <= 4999:
+		return true
+	}
+	return false
+}
+
+// FormatCloseMessage 生成关闭帧的内容
+func FormatCloseMessage(code int, text string) []byte {
+	if code == CloseNoStatusReceived {
+		return []byte{}
+	}
+	b := make([]byte, 2+len(text))
+	binary.BigEndian.PutUint16(b, uint16(code))
+	copy(b[2:], text)
+	return b
+}
+
+// ---------------------------------------------------------------- 写
+
+// WriteMessage 写入一条完整消息,超过写缓冲时自动分片
+func (c *Conn) WriteMessage(messageType int, data []byte) error {
+	w, err := c.NextWriter(messageType)
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(data); err != nil {
+		_ = w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// NextWriter 返回写入下一条消息的 writer,数据超过写缓冲时作为分片发送,Close 时发送最后一个分片
+func (c *Conn) NextWriter(messageType int) (io.WriteCloser, error) {
+	if messageType != TextMessage && messageType != BinaryMessage {
+		return nil, fmt.Errorf("websocket: invalid message type %d", messageType)
+	}
+	c.wmu.Lock()
+	err := c.writeErr
+	if err == nil && c.closeSent {
+		err = ErrCloseSent
+	}
+	if err == nil && c.writing {
+		err = ErrWriterBusy
+	}
+	if err == nil {
+		c.writing = true
+	}
+	c.wmu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	mw := &messageWriter{c: c, opcode: messageType, buf: make([]byte, 0, c.writeSize), rsv1: c.compress}
+	if c.compress {
+		return newFlateWriter(mw, c.compressLevel), nil
+	}
+	return mw, nil
+}
+
+// Ping 发送 ping,对端回复的 pong 交给 SetPongHandler 设置的 handler
+func (c *Conn) Ping(data []byte) error {
+	return c.WriteControl(PingMessage, data)
+}
+
+// WriteClose 发送关闭帧,之后继续 ReadMessage 直到收到对端的关闭帧(返回 *CloseError)完成关闭握手
+func (c *Conn) WriteClose(code int, text string) error {
+	return c.WriteControl(CloseMessage, FormatCloseMessage(code, text))
+}
+
+// WriteControl 发送控制帧,内容不能超过 125 字节,可与数据消息的写入并发调用
+func (c *Conn) WriteControl(messageType int, data []byte) error {
+	if messageType != CloseMessage && messageType != PingMessage && messageType != PongMessage {
+		return fmt.Errorf("websocket: invalid control message type %d", messageType)
+	}
+	if len(data) > maxControlPayload {
+		return errors.New("websocket: control frame too large")
+	}
+	return c.writeFrame(messageType, false, true, data)
+}
+
+func (c *Conn) writeFrame(opcode int, rsv1, fin bool, payload []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if c.writeErr != nil {
+		return c.writeErr
+	}
+	if c.closeSent {
+		return ErrCloseSent
+	}
+	frame := make([]byte, 0, maxFrameHeader+len(payload))
+	b0 := byte(opcode)
+	if fin {
+		b0 |= 0x80
+	}
+	if rsv1 {
+		b0 |= 0x40
+	}
+	frame = append(frame, b0)
+	var b1 byte
+	if !c.server {
+		b1 = 0x80
+	}
+	switch n := len(payload); {
+	case n <= 125:
+		frame = append(frame, b1|byte(n))
+	case n <= 0xffff:
+		frame = append(frame, b1|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+	default:
+		frame = append(frame, b1|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(n))
+	}
+	if c.server {
+		frame = append(frame, payload...)
+	} else {
+		var key [4]byte
+		if _, err := rand.Read(key[:]); err != nil {
+			return err
+		}
+		frame = append(frame, key[:]...)
+		start := len(frame)
+		frame = append(frame, payload...)
+		maskBytes(key, frame[start:])
+	}
+	if _, err := c.conn.Write(frame); err != nil {
+		c.writeErr = err
+		return err
+	}
+	if opcode == CloseMessage {
+		c.closeSent = true
+	}
+	return nil
+}
+
+// messageWriter 缓存写入的数据,缓冲区满时作为分片发送
+type messageWriter struct {
+	c      *Conn
+	opcode int
+	rsv1   bool
+	buf    []byte
+	closed bool
+}
+
+func (w *messageWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, errors.New("websocket: write to closed writer")
+	}
+	n := len(p)
+	for len(p) > 0 {
+		if len(w.buf) == cap(w.buf) {
+			if err := w.flush(false); err != nil {
+				return n - len(p), err
+			}
+		}
+		m := copy(w.buf[len(w.buf):cap(w.buf)], p)
+		w.buf = w.buf[:len(w.buf)+m]
+		p = p[m:]
+	}
+	return n, nil
+}
+
+func (w *messageWriter) flush(fin bool) error {
+	err := w.c.writeFrame(w.opcode, w.rsv1, fin, w.buf)
+	w.opcode, w.rsv1 = continuationFrame, false
+	w.buf = w.buf[:0]
+	return err
+}
+
+func (w *messageWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	err := w.flush(true)
+	w.c.wmu.Lock()
+	w.c.writing = false
+	w.c.wmu.Unlock()
+	return err
+}
+
+func maskBytes(key [4]byte, b []byte) {
+	for i := range b {
+		b[i] ^= key[i&3]
+	}
+}
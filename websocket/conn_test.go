@@ -0,0 +1,196 @@
+package websocket
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// pipe 返回通过 net.Pipe 相连的服务端与客户端
+func pipe(t *testing.T, server, client []Option) (*Conn, *Conn) {
+	t.Helper()
+	a, b := net.Pipe()
+	t.Cleanup(func() {
+		_ = a.Close()
+		_ = b.Close()
+	})
+	deadline := time.Now().Add(5 * time.Second)
+	_ = a.SetDeadline(deadline)
+	_ = b.SetDeadline(deadline)
+	return NewConn(a, true, server...), NewConn(b, false, client...)
+}
+
+// async 在 goroutine 中执行 f,返回等待结果的函数
+func async(f func() error) func() error {
+	ch := make(chan error, 1)
+	go func() { ch <- f() }()
+	return func() error { return <-ch }
+}
+
+func TestMessages(t *testing.T) {
+	large := bytes.Repeat([]byte("0123456789"), 1000)
+	cases := []struct {
+		name           string
+		server, client []Option
+	}{
+		{"plain", nil, nil},
+		{"fragmented", []Option{WithBufferSize(0, 7)}, []Option{WithBufferSize(0, 7)}},
+		{"compressed", []Option{WithCompression(0)}, []Option{WithCompression(0), WithBufferSize(0, 16)}},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			server, client := pipe(t, tt.server, tt.client)
+			// 服务端原样回显
+			wait := async(func() error {
+				for {
+					typ, data, err := server.ReadMessage()
+					if err != nil {
+						return err
+					}
+					if err = server.WriteMessage(typ, data); err != nil {
+						return err
+					}
+				}
+			})
+			messages := []struct {
+				typ  int
+				data []byte
+			}{
+				{TextMessage, []byte("hello")},
+				{BinaryMessage, []byte{0, 1, 2, 0xff}},
+				{TextMessage, large},
+				{TextMessage, []byte{}},
+			}
+			for _, m := range messages {
+				if err := client.WriteMessage(m.typ, m.data); err != nil {
+					t.Fatal(err)
+				}
+				typ, data, err := client.ReadMessage()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if typ != m.typ || !bytes.Equal(data, m.data) {
+					t.Fatalf("echo mismatch: type %d, %d bytes", typ, len(data))
+				}
+			}
+			// 关闭握手:客户端发起,服务端回复,双方都得到 1000
+			if err := client.WriteClose(CloseNormalClosure, "bye"); err != nil {
+				t.Fatal(err)
+			}
+			if _, _, err := client.ReadMessage(); !IsCloseError(err, CloseNormalClosure) {
+				t.Errorf("client: %v", err)
+			}
+			var ce *CloseError
+			if err := wait(); !errors.As(err, &ce) || ce.Code != CloseNormalClosure || ce.Text != "bye" {
+				t.Errorf("server: %v", err)
+			}
+		})
+	}
+}
+
+func TestPingPong(t *testing.T) {
+	server, client := pipe(t, nil, nil)
+	pong := make(chan string, 1)
+	client.SetPongHandler(func(data []byte) error {
+		pong <- string(data)
+		return nil
+	})
+	wait := async(func() error {
+		// 读取时自动回复 ping
+		_, _, err := server.ReadMessage()
+		return err
+	})
+	readClient := async(func() error {
+		_, _, err := client.ReadMessage()
+		return err
+	})
+	if err := client.Ping([]byte("tick")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case s := <-pong:
+		if s != "tick" {
+			t.Errorf("pong %q", s)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("pong not received")
+	}
+	if err := client.Ping(bytes.Repeat([]byte("x"), 126)); err == nil {
+		t.Error("oversized control frame accepted")
+	}
+	_ = server.WriteClose(CloseGoingAway, "")
+	if err := readClient(); !IsCloseError(err, CloseGoingAway) {
+		t.Errorf("client: %v", err)
+	}
+	if err := wait(); !IsCloseError(err, CloseGoingAway) {
+		t.Errorf("server: %v", err)
+	}
+}
+
+func TestReadLimit(t *testing.T) {
+	for _, compress := range []bool{false, true} {
+		var options []Option
+		if compress {
+			options = append(options, WithCompression(0))
+		}
+		server, client := pipe(t, append(options, WithReadLimit(64)), options)
+		wait := async(func() error {
+			_, _, err := server.ReadMessage()
+			return err
+		})
+		// net.Pipe 没有缓冲,客户端需要同时读取服务端的关闭帧
+		read := async(func() error {
+			_, _, err := client.ReadMessage()
+			return err
+		})
+		// 压缩后很小,解压后超过上限
+		_ = client.WriteMessage(TextMessage, []byte(strings.Repeat("a", 1024)))
+		if err := wait(); !errors.Is(err, ErrReadLimit) {
+			t.Errorf("compress=%v: server %v", compress, err)
+		}
+		if err := read(); !IsCloseError(err, CloseMessageTooBig) {
+			t.Errorf("compress=%v: client %v", compress, err)
+		}
+	}
+}
+
+func TestProtocolErrors(t *testing.T) {
+	cases := []struct {
+		name  string
+		frame []byte
+		code  int
+	}{
+		{"unmasked client frame", []byte{0x81, 0x01, 'a'}, CloseProtocolError},
+		{"continuation without start", []byte{0x80, 0x80, 0, 0, 0, 0}, CloseProtocolError},
+		{"fragmented ping", []byte{0x09, 0x80, 0, 0, 0, 0}, CloseProtocolError},
+		{"reserved opcode", []byte{0x83, 0x80, 0, 0, 0, 0}, CloseProtocolError},
+		{"rsv1 without compression", []byte{0xc1, 0x81, 0, 0, 0, 0, 'a'}, CloseProtocolError},
+		{"invalid utf-8", []byte{0x81, 0x81, 0, 0, 0, 0, 0xff}, CloseInvalidFramePayloadData},
+	}
+	for _, tt := range cases {
+		a, b := net.Pipe()
+		_ = a.SetDeadline(time.Now().Add(5 * time.Second))
+		_ = b.SetDeadline(time.Now().Add(5 * time.Second))
+		server, client := NewConn(a, true), NewConn(b, false)
+		wait := async(func() error {
+			_, _, err := server.ReadMessage()
+			return err
+		})
+		go func() { _, _ = b.Write(tt.frame) }()
+		read := async(func() error {
+			_, _, err := client.ReadMessage()
+			return err
+		})
+		if err := wait(); !IsCloseError(err, tt.code) {
+			t.Errorf("%s: server %v", tt.name, err)
+		}
+		if err := read(); !IsCloseError(err, tt.code) {
+			t.Errorf("%s: client %v", tt.name, err)
+		}
+		_ = a.Close()
+		_ = b.Close()
+	}
+}
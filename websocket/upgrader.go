@@ -0,0 +1,186 @@
+package websocket
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// acceptGUID RFC 6455 握手使用的固定 GUID
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// HandshakeError 握手请求不合法,已向客户端写出对应的错误响应
+type HandshakeError struct {
+	Status  int
+	Message string
+}
+
+func (e *HandshakeError) Error() string {
+	return "websocket: " + e.Message
+}
+
+// Upgrader 将 HTTP 请求升级为 WebSocket 连接,零值可直接使用
+type Upgrader struct {
+	CheckOrigin       func(r *http.Request) bool //为 nil 时要求 Origin 为空或与 Host 相同
+	Subprotocols      []string                   //服务端支持的子协议,按优先级排列
+	EnableCompression bool                       //客户端提议时启用 permessage-deflate
+	CompressionLevel  int                        //压缩级别,0 为默认级别
+	ReadLimit         int64                      //单条消息最大长度,0 为 DefaultReadLimit
+	ReadBufferSize    int
+	WriteBufferSize   int //同时决定写入分片的大小
+}
+
+// Upgrade 校验握手请求并劫持连接,header 中的字段(如 Set-Cookie)随 101 响应一起发送;
+// 校验失败时写出 4xx 响应并返回 *HandshakeError
+func (u *Upgrader) Upgrade(w http.ResponseWriter, r *http.Request, header http.Header) (*Conn, error) {
+	if r.Method != http.MethodGet {
+		return nil, u.fail(w, http.StatusMethodNotAllowed, "request method is not GET")
+	}
+	if !headerContains(r.Header, "Connection", "upgrade") {
+		return nil, u.fail(w, http.StatusBadRequest, "'upgrade' token not found in 'Connection' header")
+	}
+	if !headerContains(r.Header, "Upgrade", "websocket") {
+		return nil, u.fail(w, http.StatusBadRequest, "'websocket' token not found in 'Upgrade' header")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		return nil, u.fail(w, http.StatusUpgradeRequired, "unsupported version")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if b, err := base64.StdEncoding.DecodeString(key); err != nil || len(b) != 16 {
+		return nil, u.fail(w, http.StatusBadRequest, "invalid 'Sec-WebSocket-Key' header")
+	}
+	checkOrigin := u.CheckOrigin
+	if checkOrigin == nil {
+		checkOrigin = SameOrigin
+	}
+	if !checkOrigin(r) {
+		return nil, u.fail(w, http.StatusForbidden, "origin not allowed")
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, u.fail(w, http.StatusInternalServerError, "response does not implement http.Hijacker")
+	}
+
+	subprotocol := u.selectSubprotocol(r)
+	var extension string
+	compress := false
+	if u.EnableCompression {
+		extension, compress = negotiateDeflate(r.Header.Values("Sec-WebSocket-Extensions"))
+	}
+
+	netConn, brw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+	// 清除 http.Server 设置的读写超时
+	_ = netConn.SetDeadline(time.Time{})
+
+	var b strings.Builder
+	b.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: ")
+	b.WriteString(acceptKey(key))
+	b.WriteString("\r\n")
+	if subprotocol != "" {
+		b.WriteString("Sec-WebSocket-Protocol: " + subprotocol + "\r\n")
+	}
+	if extension != "" {
+		b.WriteString("Sec-WebSocket-Extensions: " + extension + "\r\n")
+	}
+	for k, vs := range header {
+		switch http.CanonicalHeaderKey(k) {
+		case "Upgrade", "Connection", "Sec-Websocket-Accept", "Sec-Websocket-Protocol", "Sec-Websocket-Extensions",
+			"Content-Type", "Content-Length", "Vary":
+			continue
+		}
+		for _, v := range vs {
+			b.WriteString(k + ": " + strings.NewReplacer("\r", "", "\n", "").Replace(v) + "\r\n")
+		}
+	}
+	b.WriteString("\r\n")
+	if _, err = brw.WriteString(b.String()); err == nil {
+		err = brw.Flush()
+	}
+	if err != nil {
+		_ = netConn.Close()
+		return nil, err
+	}
+
+	options := []Option{WithReader(brw.Reader), WithBufferSize(u.ReadBufferSize, u.WriteBufferSize)}
+	if u.ReadLimit > 0 {
+		options = append(options, WithReadLimit(u.ReadLimit))
+	}
+	if compress {
+		options = append(options, WithCompression(u.CompressionLevel))
+	}
+	c := NewConn(netConn, true, options...)
+	c.subprotocol = subprotocol
+	return c, nil
+}
+
+func (u *Upgrader) fail(w http.ResponseWriter, status int, message string) error {
+	http.Error(w, http.StatusText(status), status)
+	return &HandshakeError{Status: status, Message: message}
+}
+
+// selectSubprotocol 按服务端优先级选择客户端也支持的子协议
+func (u *Upgrader) selectSubprotocol(r *http.Request) string {
+	var offered []string
+	for _, v := range r.Header.Values("Sec-WebSocket-Protocol") {
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				offered = append(offered, s)
+			}
+		}
+	}
+	for _, p := range u.Subprotocols {
+		for _, o := range offered {
+			if o == p {
+				return p
+			}
+		}
+	}
+	return ""
+}
+
+// SameOrigin 默认的来源检查:没有 Origin(非浏览器客户端)或 Origin 的 host 与请求 Host 相同
+func SameOrigin(r *http.Request) bool {
+	return MatchOrigin(r.Header.Get("Origin"), r.Host)
+}
+
+// MatchOrigin origin 为空或其 host 与 host 相同,经过反向代理时 host 应为客户端访问的地址
+func MatchOrigin(origin, host string) bool {
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, host)
+}
+
+// IsUpgrade 请求是否为 WebSocket 握手
+func IsUpgrade(r *http.Request) bool {
+	return headerContains(r.Header, "Connection", "upgrade") && headerContains(r.Header, "Upgrade", "websocket")
+}
+
+func acceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// headerContains 逗号分隔的头部字段中是否包含 token(忽略大小写)
+func headerContains(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, s := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(s), token) {
+				return true
+			}
+		}
+	}
+	return false
+}